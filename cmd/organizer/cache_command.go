@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	"organizer/internal/ai"
+	"organizer/internal/configuration"
+)
+
+// runCacheCommand manages the AI response cache: 'clear' removes every entry and 'prune'
+// removes the entries older than the configured TTL.
+func runCacheCommand(arguments []string) error {
+
+	if len(arguments) != 1 || (arguments[0] != "clear" && arguments[0] != "prune") {
+		return fmt.Errorf("usage: organizer cache clear|prune")
+	}
+
+	cacheSettings, err := configuration.NewCacheSettings()
+
+	if err != nil {
+		return err
+	}
+
+	if cacheSettings.Directory == "" {
+		return fmt.Errorf("%s environment variable is not set", configuration.AiCacheDirectoryEnvVarName)
+	}
+
+	cache, err := ai.NewResponseCache(cacheSettings.Directory, cacheSettings.Ttl)
+
+	if err != nil {
+		return err
+	}
+
+	var removed int
+
+	switch arguments[0] {
+	case "clear":
+		removed, err = cache.Clear()
+	case "prune":
+		if cacheSettings.Ttl == 0 {
+			return fmt.Errorf("%s environment variable is not set, no entry can expire", configuration.AiCacheTtlEnvVarName)
+		}
+		removed, err = cache.Prune()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d cache entries removed from %s\n", removed, cacheSettings.Directory)
+
+	return nil
+}
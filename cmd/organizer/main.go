@@ -6,17 +6,34 @@ import (
 	"organizer/internal/audit"
 	"organizer/internal/copier"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
+	"time"
 
+	"organizer/internal/abstractions/entities"
 	"organizer/internal/ai"
 	"organizer/internal/analyzer"
 	"organizer/internal/configuration"
+	"organizer/internal/prompts"
+	"organizer/internal/report"
+	"organizer/internal/review"
 	"organizer/internal/scanner"
 )
 
 func main() {
 
-	ctx := context.Background()
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		if err := runCacheCommand(os.Args[2:]); err != nil {
+			fmt.Printf("Unable to run the cache command: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	//	Ctrl-C or a termination signal cancels the requests in flight and stops the services gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	waitGroup := &sync.WaitGroup{}
 
@@ -35,17 +52,28 @@ func main() {
 		os.Exit(1)
 	}
 
+	reportService := report.New(configurationService)
+	reviewService := review.New()
+
 	//	Initializes the AI proxy
-	aiProxy, err := ai.New(configurationService, ctx)
+	aiProxy, err := ai.New(configurationService, auditService, reportService)
 
 	if err != nil {
 		fmt.Printf("Unable to start the AI proxy: %v\n", err)
 		os.Exit(1)
 	}
 
-	scannerService := scanner.New(configurationService, aiProxy, auditService, ctx, waitGroup)
-	analyzerService := analyzer.New(aiProxy, scannerService, auditService, ctx, waitGroup)
-	copierService := copier.New(configurationService, analyzerService, auditService, ctx, waitGroup)
+	//	Initializes the prompts
+	promptService, err := prompts.New(configurationService)
+
+	if err != nil {
+		fmt.Printf("Unable to load the prompts: %v\n", err)
+		os.Exit(1)
+	}
+
+	scannerService := scanner.New(configurationService, aiProxy, promptService, auditService, reportService, reviewService, ctx, waitGroup)
+	analyzerService := analyzer.New(configurationService, aiProxy, promptService, scannerService, auditService, reportService, reviewService, ctx, waitGroup)
+	copierService := copier.New(configurationService, analyzerService, auditService, reportService, ctx, waitGroup)
 
 	//	Runs the application
 	scannerService.Scan()
@@ -53,4 +81,25 @@ func main() {
 	copierService.Run()
 
 	waitGroup.Wait()
+
+	if ctx.Err() != nil {
+		auditService.Log(entities.Audit{Severity: entities.Warning, Timestamp: time.Now(), Text: fmt.Sprintf("The run was cancelled by a signal.")})
+		reportService.RecordCancellation("interrupted by a signal")
+	}
+
+	aiProxy.Close()
+
+	if err := reportService.Write(); err != nil {
+		fmt.Printf("Unable to write the report: %v\n", err)
+	}
+
+	if err := reviewService.Write(); err != nil {
+		fmt.Printf("Unable to write the review queue: %v\n", err)
+	}
+
+	//	The folders that failed are listed in the report, the others having been processed
+	if reportService.HasFailures() {
+		stop()
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"organizer/internal/abstractions"
+	"organizer/internal/abstractions/entities"
+	"organizer/internal/ai"
+	"organizer/internal/analyzer"
+	"organizer/internal/audit"
+	"organizer/internal/configuration"
+	"organizer/internal/copier"
+	"organizer/internal/prompts"
+	"organizer/internal/report"
+	"organizer/internal/review"
+	"organizer/internal/scanner"
+)
+
+// workingDirectory is the folder of the scans of the pipeline tests, relative to their temporary directory.
+const workingDirectory = "scans"
+
+// TestPipelineOffline runs the scanner, the analyzer and the copier against the scripted backend.
+func TestPipelineOffline(t *testing.T) {
+
+	pages := map[string][]byte{"b.jpg": newJpeg(t, 1), "a.jpg": newJpeg(t, 2)}
+
+	aiProxy := ai.NewScripted().
+		OnRespond("sort them", `[{"file":"b.jpg","number":1},{"file":"a.jpg","number":2}]`).
+		OnRespond("cover", `{"title":"Mag","months":[1,2],"year":1999,"number":3,"confidence":0.9}`)
+
+	reportService := runPipeline(t, aiProxy, pages)
+
+	issueFolder := filepath.Join(workingDirectory, copier.Prefix+"Mag", "Numéro 03 | Janvier - Février 1999")
+
+	for file, scan := range map[string]string{"001.jpg": "b.jpg", "002.jpg": "a.jpg"} {
+		content, err := os.ReadFile(filepath.Join(issueFolder, file))
+		if err != nil {
+			t.Fatalf("the page %s was not copied: %v", file, err)
+		}
+		if !bytes.Equal(content, pages[scan]) {
+			t.Errorf("the page %s is not a copy of %s", file, scan)
+		}
+	}
+
+	if reportService.HasFailures() {
+		t.Error("the run recorded failures")
+	}
+
+	if requests := aiProxy.Requests(); len(requests) < 2 {
+		t.Errorf("expected the page ordering and the cover analysis to be asked, got %d request(s)", len(requests))
+	}
+}
+
+// TestPipelineRecordsRejectedCovers checks that a cover whose answers are rejected on every tier fails its folder.
+func TestPipelineRecordsRejectedCovers(t *testing.T) {
+
+	aiProxy := ai.NewScripted().
+		OnRespond("sort them", `[{"file":"a.jpg","number":1}]`).
+		OnRespond("cover", `{"title":"","months":[],"year":0,"number":0,"confidence":0.1}`)
+
+	reportService := runPipeline(t, aiProxy, map[string][]byte{"a.jpg": newJpeg(t, 1)})
+
+	if !reportService.HasFailures() {
+		t.Error("expected the folder whose cover is rejected to be recorded as failed")
+	}
+}
+
+// TestPipelineStopsOnceTheBudgetIsExhausted checks that no folder is emitted once the budget refuses the requests,
+// whether its pages are ordered by the AI or not.
+func TestPipelineStopsOnceTheBudgetIsExhausted(t *testing.T) {
+
+	refuse := func(ai.ScriptedRequest) (string, error) { return "", abstractions.ErrBudgetExhausted }
+
+	aiProxy := ai.NewScripted().
+		On("exhaust", refuse).
+		On("cover", refuse)
+
+	var nothing any
+
+	if err := aiProxy.SendRequest(context.Background(), entities.AiCall{}, "exhaust the budget", &nothing); !errors.Is(err, abstractions.ErrBudgetExhausted) {
+		t.Fatalf("expected the budget to be exhausted, got %v", err)
+	}
+
+	reportService := runPipeline(t, aiProxy, map[string][]byte{"page 1.jpg": newJpeg(t, 1), "page 2.jpg": newJpeg(t, 2)})
+
+	if requests := aiProxy.Requests(); len(requests) != 1 {
+		t.Errorf("expected the folder not to be analyzed, got %d request(s)", len(requests))
+	}
+
+	if reportService.HasFailures() {
+		t.Error("expected the folder to be left unprocessed rather than failed")
+	}
+}
+
+// TestPipelineCopiesThePdfPages checks that a folder of PDF pages is analyzed and copied like a folder of images.
+func TestPipelineCopiesThePdfPages(t *testing.T) {
+
+	pages := map[string][]byte{"page 1.pdf": []byte("%PDF-1.7\n1"), "page 2.pdf": []byte("%PDF-1.7\n2")}
+
+	aiProxy := ai.NewScripted().
+		OnRespond("sort them", `[{"file":"page 1.pdf","number":1},{"file":"page 2.pdf","number":2}]`).
+		OnRespond("cover", `{"title":"Mag","months":[1],"year":1999,"number":3,"confidence":0.9}`)
+
+	reportService := runPipeline(t, aiProxy, pages)
+
+	issueFolder := filepath.Join(workingDirectory, copier.Prefix+"Mag", "Numéro 03 | Janvier 1999")
+
+	for file, scan := range map[string]string{"001.pdf": "page 1.pdf", "002.pdf": "page 2.pdf"} {
+		content, err := os.ReadFile(filepath.Join(issueFolder, file))
+		if err != nil {
+			t.Fatalf("the page %s was not copied: %v", file, err)
+		}
+		if !bytes.Equal(content, pages[scan]) {
+			t.Errorf("the page %s is not a copy of %s", file, scan)
+		}
+	}
+
+	if reportService.HasFailures() {
+		t.Error("the run recorded failures")
+	}
+}
+
+// runPipeline runs the scanner, the analyzer and the copier on a single folder of the given pages.
+func runPipeline(t *testing.T, aiProxy *ai.ScriptedAiProxy, pages map[string][]byte) *report.ReportService {
+
+	//	The audit log and the reports are written to the current directory
+	t.Chdir(t.TempDir())
+
+	folder := filepath.Join(workingDirectory, "Mag 3")
+
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for file, content := range pages {
+		if err := os.WriteFile(filepath.Join(folder, file), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv(configuration.OpenaiApiKeyEnvVarName, "offline")
+	t.Setenv(configuration.WorkingDirectoryEnvVarName, workingDirectory)
+	t.Setenv(configuration.LocalPageOrderingEnvVarName, "false")
+
+	configurationService, err := configuration.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auditService, err := audit.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	promptService, err := prompts.New(configurationService)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reportService := report.New(configurationService)
+	reviewService := review.New()
+	waitGroup := &sync.WaitGroup{}
+	ctx := context.Background()
+
+	scannerService := scanner.New(configurationService, aiProxy, promptService, auditService, reportService, reviewService, ctx, waitGroup)
+	analyzerService := analyzer.New(configurationService, aiProxy, promptService, scannerService, auditService, reportService, reviewService, ctx, waitGroup)
+	copierService := copier.New(configurationService, analyzerService, auditService, reportService, ctx, waitGroup)
+
+	scannerService.Scan()
+	analyzerService.Run()
+	copierService.Run()
+
+	waitGroup.Wait()
+
+	return reportService
+}
+
+// newJpeg returns a small JPEG image, its diagonal drawn every given number of pixels.
+func newJpeg(t *testing.T, step int) []byte {
+
+	canvas := image.NewRGBA(image.Rect(0, 0, 16, 16))
+
+	for x := 0; x < 16; x += step {
+		canvas.Set(x, x, color.White)
+	}
+
+	var buffer bytes.Buffer
+
+	if err := jpeg.Encode(&buffer, canvas, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
@@ -2,7 +2,10 @@ module organizer
 
 go 1.25
 
-require github.com/openai/openai-go/v3 v3.8.1
+require (
+	github.com/openai/openai-go/v3 v3.8.1
+	golang.org/x/image v0.25.0
+)
 
 require (
 	github.com/tidwall/gjson v1.18.0 // indirect
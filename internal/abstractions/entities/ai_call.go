@@ -0,0 +1,20 @@
+package entities
+
+// AiCall attributes an AI request to the folder and the stage of the pipeline it is made for.
+type AiCall struct {
+	Folder string
+	Stage  AiStage
+	//	Tier of the escalation cascade of the stage, 0 being the model configured for the stage
+	Tier int
+	//	Vote of the self-consistency voting, 0 being the first or only one
+	Vote int
+}
+
+type AiStage string
+
+const (
+	PageOrderingStage   AiStage = "page-ordering"
+	CoverAnalysisStage  AiStage = "cover-analysis"
+	TableOfContentStage AiStage = "table-of-content"
+	GameTestStage       AiStage = "game-test"
+)
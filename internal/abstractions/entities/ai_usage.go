@@ -0,0 +1,24 @@
+package entities
+
+import "time"
+
+// AiUsage is the token usage of one AI request and its cost. The provider is the one which answered, none for
+// the answers of the cache.
+type AiUsage struct {
+	Timestamp         time.Time `json:"timestamp"`
+	Folder            string    `json:"folder"`
+	Stage             AiStage   `json:"stage"`
+	Model             string    `json:"model"`
+	Provider          string    `json:"provider,omitempty"`
+	Tier              int       `json:"tier"`
+	Vote              int       `json:"vote"`
+	CacheHit          bool      `json:"cacheHit"`
+	Batch             bool      `json:"batch"`
+	Reask             bool      `json:"reask"`
+	Repairs           []string  `json:"repairs,omitempty"`
+	InputTokens       int64     `json:"inputTokens"`
+	CachedInputTokens int64     `json:"cachedInputTokens"`
+	OutputTokens      int64     `json:"outputTokens"`
+	ReasoningTokens   int64     `json:"reasoningTokens"`
+	Cost              float64   `json:"cost"`
+}
@@ -4,4 +4,5 @@ type Magazine struct {
 	Metadata MagazineMetadata
 	Pages    []MagazinePage
 	Folder   string
+	Sidecars []string
 }
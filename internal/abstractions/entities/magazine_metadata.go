@@ -5,4 +5,6 @@ type MagazineMetadata struct {
 	Number uint8   `json:"number"`
 	Month  []uint8 `json:"months"`
 	Year   uint16  `json:"year"`
+	//	Confidence of the model in its answer, from 0 to 1
+	Confidence float64 `json:"confidence"`
 }
@@ -1,6 +1,8 @@
 package entities
 
+// MagazinePages are the ordered pages of a folder, along with its sidecar files such as notes and checksums.
 type MagazinePages struct {
-	Pages  []MagazinePage
-	Folder string
+	Pages    []MagazinePage
+	Folder   string
+	Sidecars []string
 }
@@ -1,6 +1,39 @@
 package abstractions
 
+import "errors"
+
 const (
 	//	Error codes
 	ERR_OPENAI_API_KEY_MISSING = "ERR_OPENAI_API_KEY_MISSING"
 )
+
+var (
+	//	Returned by the AI proxy when a request would exceed the budget of the run
+	ErrBudgetExhausted = errors.New("the AI budget of the run is exhausted")
+)
+
+// AttemptsError is returned by the AI proxy when a request failed, with the number of attempts it made.
+type AttemptsError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *AttemptsError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AttemptsError) Unwrap() error {
+	return e.Err
+}
+
+// AttemptsOf returns the number of attempts made by the failed operation, 1 when it was not retried.
+func AttemptsOf(err error) int {
+
+	var attemptsError *AttemptsError
+
+	if errors.As(err, &attemptsError) {
+		return attemptsError.Attempts
+	}
+
+	return 1
+}
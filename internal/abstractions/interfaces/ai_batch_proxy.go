@@ -0,0 +1,24 @@
+package interfaces
+
+import (
+	"context"
+	"io"
+
+	"organizer/internal/abstractions/entities"
+)
+
+// AiBatchProxy is implemented by the AI proxies able to send requests in bulk, answered
+// asynchronously at a lower price.
+type AiBatchProxy interface {
+	NewBatch() (AiBatch, error)
+}
+
+// AiBatch collects requests until it is run.
+type AiBatch interface {
+	// Add adds a request whose response is decoded into result once the batch has run.
+	// The readers are consumed before Add returns.
+	Add(ctx context.Context, call entities.AiCall, assistantPrompt string, readers []io.Reader, result any) error
+	// Run submits the requests, waits for their responses and returns the error of each
+	// request, in the order they were added. The batch is abandoned when the context is done.
+	Run(ctx context.Context) ([]error, error)
+}
@@ -0,0 +1,19 @@
+package interfaces
+
+import (
+	"context"
+	"io"
+
+	"organizer/internal/abstractions/entities"
+)
+
+// AiProxy sends prompts to a model on behalf of a call of the pipeline. The response is decoded
+// into result, a pointer whose type defines the expected JSON structure. A request is abandoned
+// when the context of the caller is done. Once the budget of the run is exhausted, every request
+// is refused with abstractions.ErrBudgetExhausted.
+type AiProxy interface {
+	SendRequest(ctx context.Context, call entities.AiCall, assistantPrompt string, result any) error
+	SendRequestWithImage(ctx context.Context, call entities.AiCall, assistantPrompt string, reader io.Reader, result any) error
+	SendRequestWithImages(ctx context.Context, call entities.AiCall, assistantPrompt string, readers []io.Reader, result any) error
+	BudgetExhausted() bool
+}
@@ -3,11 +3,21 @@ package ai
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"reflect"
 	"strings"
+	"time"
 
+	"organizer/internal/abstractions"
+	"organizer/internal/abstractions/entities"
+	"organizer/internal/audit"
 	"organizer/internal/configuration"
+	"organizer/internal/imaging"
+	"organizer/internal/report"
 
 	openai "github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
@@ -16,102 +26,729 @@ import (
 	"github.com/openai/openai-go/v3/shared"
 )
 
+// AiProxy is the OpenAI backend of interfaces.AiProxy.
 type AiProxy struct {
 	textModel          shared.ResponsesModel
 	imageAnalysisModel shared.ResponsesModel
-	client             *openai.Client
-	context            context.Context
+	tasks              map[entities.AiStage]configuration.AiTask
+	modelCapabilities  map[string]ModelCapabilities
+	retryPolicy        retryPolicy
+	cache              *ResponseCache
+	budget             *budget
+	governor           *governor
+	imageOptimizer     *imaging.ImageOptimizer
+	//	Images uploaded through the Files API, nil when they are sent inline
+	fileUploads       *fileUploads
+	batchPollInterval time.Duration
+	//	Timeout of a whole call, zero meaning none
+	callTimeout time.Duration
+	client      *openai.Client
+	//	Name of the primary provider, and the provider the requests fail over to, nil when there is none
+	provider      string
+	fallback      *aiFallback
+	auditService  *audit.AuditService
+	reportService *report.ReportService
 }
 
 func New(
 	configurationService *configuration.ConfigurationService,
-	context context.Context) (*AiProxy, error) {
+	auditService *audit.AuditService,
+	reportService *report.ReportService) (*AiProxy, error) {
 
-	openaiClient := openai.NewClient(
-		option.WithAPIKey(configurationService.OpenAiApiKey),
-	)
+	modelCapabilities, err := newModelCapabilityTable(configurationService.ModelCapabilities)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for stage, task := range configurationService.AiTasks {
+		for _, model := range append(task.Models(), task.VoteModels...) {
+
+			if task.Images && !modelCapabilities[model].Vision {
+				return nil, fmt.Errorf("the model '%s' of the %s is not declared with the '%s' capability", model, stage, VisionCapability)
+			}
+
+			if task.ReasoningEffort != "" && !modelCapabilities[model].Reasoning {
+				return nil, fmt.Errorf("the model '%s' of the %s has a reasoning effort but is not declared with the '%s' capability", model, stage, ReasoningCapability)
+			}
+		}
+	}
+
+	var httpClient *http.Client
+
+	if configurationService.AiCassetteMode != "" {
+
+		transport, err := newCassetteTransport(configurationService.AiCassetteMode, configurationService.AiCassetteDirectory)
+
+		if err != nil {
+			return nil, err
+		}
+
+		httpClient = &http.Client{Transport: transport}
+
+		auditService.Log(entities.Audit{
+			Severity:  entities.Information,
+			Timestamp: time.Now(),
+			Text:      fmt.Sprintf("AI exchanges are in %s mode with the cassettes of %s", configurationService.AiCassetteMode, configurationService.AiCassetteDirectory)})
+	}
+
+	client := newClient(configurationService.OpenAiBaseUrl, configurationService.OpenAiApiKey, configurationService.AiRequestTimeout, httpClient)
+
+	fallback, err := newAiFallback(configurationService, modelCapabilities, httpClient)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var cache *ResponseCache
+
+	if configurationService.AiCache.Directory != "" {
+		cache, err = NewResponseCache(configurationService.AiCache.Directory, configurationService.AiCache.Ttl)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var uploads *fileUploads
+
+	if configurationService.AiImageUpload {
+		uploads = newFileUploads()
+	}
 
 	return &AiProxy{
-		client:             &openaiClient,
-		context:            context,
-		textModel:          openai.ChatModelGPT5Nano,
-		imageAnalysisModel: openai.ChatModelGPT5Mini,
+		client:             client,
+		provider:           providerName(configurationService.OpenAiBaseUrl),
+		fallback:           fallback,
+		auditService:       auditService,
+		reportService:      reportService,
+		textModel:          configurationService.TextModel,
+		imageAnalysisModel: configurationService.ImageAnalysisModel,
+		tasks:              configurationService.AiTasks,
+		modelCapabilities:  modelCapabilities,
+		cache:              cache,
+		imageOptimizer:     imaging.NewImageOptimizer(configurationService.AiImageMaxEdge, configurationService.AiImageQuality),
+		fileUploads:        uploads,
+		batchPollInterval:  configurationService.AiBatchPollInterval,
+		callTimeout:        configurationService.AiCallTimeout,
+		governor:           newGovernor(configurationService.AiMaxInFlight, configurationService.AiRequestsPerMinute, configurationService.AiTokensPerMinute),
+		budget: &budget{
+			maxTokens:     int64(configurationService.AiBudgetTokens),
+			maxCost:       configurationService.AiBudgetCost,
+			reportService: reportService,
+		},
+		retryPolicy: retryPolicy{
+			maxAttempts: configurationService.AiMaxAttempts,
+			baseDelay:   retryBaseDelay,
+			maxDelay:    retryMaxDelay,
+		},
 	}, nil
 }
 
-func (aiProxy *AiProxy) SendRequest(assistantPrompt string) (string, error) {
+// newClient returns a client of an OpenAI-compatible server. Retries are handled by the proxy so that they are audited,
+// the timeout applying to each attempt.
+func newClient(baseUrl string, apiKey string, timeout time.Duration, httpClient *http.Client, extraOptions ...option.RequestOption) *openai.Client {
 
-	response, err := aiProxy.client.Responses.New(aiProxy.context, responses.ResponseNewParams{
-		Input: responses.ResponseNewParamsInputUnion{
-			OfInputItemList: []responses.ResponseInputItemUnionParam{
-				{
-					OfInputMessage: &responses.ResponseInputItemMessageParam{
-						Role: "user",
-						Content: responses.ResponseInputMessageContentListParam{
-							{
-								OfInputText: &responses.ResponseInputTextParam{
-									Text: assistantPrompt,
-								},
-							},
-						},
-					},
-				},
-			},
-		},
-		Model: aiProxy.textModel,
+	options := []option.RequestOption{
+		option.WithMaxRetries(0),
+		option.WithRequestTimeout(timeout),
+	}
+
+	if baseUrl != "" {
+		options = append(options, option.WithBaseURL(baseUrl))
+	}
+
+	if apiKey != "" {
+		options = append(options, option.WithAPIKey(apiKey))
+	}
+
+	if httpClient != nil {
+		options = append(options, option.WithHTTPClient(httpClient))
+	}
+
+	client := openai.NewClient(append(options, extraOptions...)...)
+
+	return &client
+}
+
+// SendRequest sends a text prompt and decodes the response into result, whose type defines
+// the JSON schema of the structured output. The model is the one configured for the stage of the call.
+func (aiProxy *AiProxy) SendRequest(ctx context.Context, call entities.AiCall, assistantPrompt string, result any) error {
+	return aiProxy.send(ctx, call, assistantPrompt, nil, result)
+}
+
+func (aiProxy *AiProxy) SendRequestWithImage(ctx context.Context, call entities.AiCall, assistantPrompt string, reader io.Reader, result any) error {
+	return aiProxy.SendRequestWithImages(ctx, call, assistantPrompt, []io.Reader{reader}, result)
+}
+
+func (aiProxy *AiProxy) SendRequestWithImages(ctx context.Context, call entities.AiCall, assistantPrompt string, readers []io.Reader, result any) error {
+
+	images, err := readImages(readers)
+
+	if err != nil {
+		return err
+	}
+
+	return aiProxy.send(ctx, call, assistantPrompt, images, result)
+}
+
+// BudgetExhausted tells whether the budget of the run refuses the requests.
+func (aiProxy *AiProxy) BudgetExhausted() bool {
+	return aiProxy.budget.exhausted.Load()
+}
+
+// task returns the configuration of the stage, the stages without one using the default text or image model.
+func (aiProxy *AiProxy) task(stage entities.AiStage, withImages bool) configuration.AiTask {
+
+	if task, found := aiProxy.tasks[stage]; found {
+		return task
+	}
+
+	if withImages {
+		return configuration.AiTask{Model: aiProxy.imageAnalysisModel, Images: true}
+	}
+
+	return configuration.AiTask{Model: aiProxy.textModel}
+}
+
+func readImages(readers []io.Reader) ([][]byte, error) {
+
+	images := make([][]byte, 0, len(readers))
+
+	for _, reader := range readers {
+
+		image, err := io.ReadAll(reader)
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the image: %v", err)
+		}
+
+		images = append(images, image)
+	}
+
+	return images, nil
+}
+
+// aiRequest is a request on its way through the proxy.
+type aiRequest struct {
+	call              entities.AiCall
+	model             shared.ResponsesModel
+	reasoningEffort   string
+	maxOutputTokens   int
+	assistantPrompt   string
+	images            [][]byte
+	result            any
+	schema            *outputSchema
+	structuredOutputs bool
+	cacheKey          string
+	//	Answer of the first request that could not be decoded, when asked again
+	reask *aiReask
+	//	Whether the request is sent to the fallback provider
+	fallback bool
+	//	Estimated spend held by the budget until the request is settled, nil when the budget is disabled
+	reservation *budgetReservation
+}
+
+// aiReask is an answer that could not be decoded and the error of the decoder, sent back to the model.
+type aiReask struct {
+	outputText  string
+	decodeError string
+}
+
+func (aiProxy *AiProxy) newRequest(call entities.AiCall, assistantPrompt string, images [][]byte, result any) (*aiRequest, error) {
+
+	schema, err := newOutputSchema(result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	task := aiProxy.task(call.Stage, len(images) > 0)
+	models := task.Models()
+
+	if call.Tier < 0 || call.Tier >= len(models) {
+		return nil, fmt.Errorf("the %s has no model for the tier %d", call.Stage, call.Tier)
+	}
+
+	model := task.VoteModel(call.Tier, call.Vote)
+
+	request := aiRequest{
+		call:            call,
+		model:           model,
+		reasoningEffort: task.ReasoningEffort,
+		maxOutputTokens: task.MaxOutputTokens,
+		assistantPrompt: assistantPrompt,
+		images:          images,
+		result:          result,
+		schema:          schema,
+		//	Models without structured outputs only get the format described in the prompt
+		structuredOutputs: aiProxy.modelCapabilities[model].StructuredOutputs,
+	}
+
+	if aiProxy.cache != nil {
+		request.cacheKey = cacheKey(model, schema.name, call.Vote, assistantPrompt, images)
+	}
+
+	return &request, nil
+}
+
+func (aiProxy *AiProxy) send(ctx context.Context, call entities.AiCall, assistantPrompt string, images [][]byte, result any) error {
+
+	ctx, cancel := aiProxy.withCallTimeout(ctx)
+	defer cancel()
+
+	request, err := aiProxy.newRequest(call, assistantPrompt, images, result)
+
+	if err != nil {
+		return err
+	}
+
+	if found, err := aiProxy.answerFromCache(request); found {
+		return err
+	}
+
+	if err := aiProxy.reserveBudget(request); err != nil {
+		return err
+	}
+
+	defer request.reservation.settle()
+
+	answered, response, err := aiProxy.execute(ctx, request)
+
+	//	The request sent to the fallback provider holds a reservation of its own
+	defer answered.reservation.settle()
+
+	if err != nil {
+		return fmt.Errorf("unable to process the prompt: %w", err)
+	}
+
+	return aiProxy.complete(ctx, answered, response, false)
+}
+
+// withCallTimeout bounds a call with its retries, failover and reask, the timeout of the client only bounding each
+// attempt.
+func (aiProxy *AiProxy) withCallTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+
+	if aiProxy.callTimeout == 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeoutCause(ctx, aiProxy.callTimeout, fmt.Errorf("the call exceeded its timeout of %v", aiProxy.callTimeout))
+}
+
+// execute sends the request in the turn given by the governor, retrying it according to the retry policy. When the
+// primary provider still fails after the failover threshold, the request fails over to the fallback provider for the
+// rest of the call. It returns the request as sent to the provider which answered, the caller settling its reservation.
+func (aiProxy *AiProxy) execute(ctx context.Context, request *aiRequest) (*aiRequest, *responses.Response, error) {
+
+	canFailOver := aiProxy.fallback != nil && !request.fallback
+	maxAttempts := aiProxy.retryPolicy.maxAttempts
+
+	if canFailOver {
+		maxAttempts = min(maxAttempts, aiProxy.fallback.threshold)
+	}
+
+	params, err := aiProxy.newResponseParams(ctx, request)
+
+	//	Such as a scan that cannot be decoded, which the fallback provider would not read either
+	if err != nil {
+		return request, nil, err
+	}
+
+	var response *responses.Response
+
+	err = aiProxy.withAttempts(ctx, fmt.Sprintf("Request to the model '%s' of %s", request.model, aiProxy.providerOf(request)), maxAttempts, func() error {
+
+		release, err := aiProxy.waitForGovernor(ctx, request)
+
+		if err != nil {
+			return err
+		}
+
+		response, err = aiProxy.clientOf(request).Responses.New(ctx, params)
+
+		if err != nil {
+			release(0)
+			return err
+		}
+
+		release(response.Usage.InputTokens + response.Usage.OutputTokens)
+
+		return nil
+	})
+
+	if err == nil || !canFailOver || ctx.Err() != nil {
+		return request, response, err
+	}
+
+	primaryAttempts := abstractions.AttemptsOf(err)
+
+	fallbackRequest, err := aiProxy.failOver(request, err)
+
+	if err != nil {
+		return request, nil, err
+	}
+
+	fallbackRequest, response, err = aiProxy.execute(ctx, fallbackRequest)
+
+	//	The attempts made on both providers
+	var attemptsError *abstractions.AttemptsError
+
+	if errors.As(err, &attemptsError) {
+		attemptsError.Attempts += primaryAttempts
+	}
+
+	return fallbackRequest, response, err
+}
+
+// reask sends the request once more, along with the answer that could not be decoded and the error of the decoder.
+func (aiProxy *AiProxy) reask(ctx context.Context, request *aiRequest, outputText string, decodeErr error) error {
+
+	aiProxy.auditService.Log(entities.Audit{
+		Severity:  entities.Warning,
+		Timestamp: time.Now(),
+		Text:      fmt.Sprintf("Response of the model '%s' for the %s of '%s' could not be decoded, asking again: %v", request.model, request.call.Stage, request.call.Folder, decodeErr)})
+
+	reask := *request
+	reask.reask = &aiReask{outputText: outputText, decodeError: decodeErr.Error()}
+
+	if err := aiProxy.reserveBudget(&reask); err != nil {
+		return err
+	}
+
+	defer reask.reservation.settle()
+
+	answered, response, err := aiProxy.execute(ctx, &reask)
+
+	defer answered.reservation.settle()
+
+	if err != nil {
+		return fmt.Errorf("unable to process the prompt asked again: %w", err)
+	}
+
+	return aiProxy.complete(ctx, answered, response, false)
+}
+
+// answerFromCache decodes the cached response of the request, if any.
+func (aiProxy *AiProxy) answerFromCache(request *aiRequest) (bool, error) {
+
+	if aiProxy.cache == nil {
+		return false, nil
+	}
+
+	outputText, found := aiProxy.cache.Get(request.cacheKey)
+
+	if !found {
+		aiProxy.auditService.Log(entities.Audit{
+			Severity:  entities.Debug,
+			Timestamp: time.Now(),
+			Text:      fmt.Sprintf("AI cache miss for the model '%s' (key %s)", request.model, request.cacheKey)})
+		return false, nil
+	}
+
+	aiProxy.auditService.Log(entities.Audit{
+		Severity:  entities.Debug,
+		Timestamp: time.Now(),
+		Text:      fmt.Sprintf("AI cache hit for the model '%s' (key %s)", request.model, request.cacheKey)})
+
+	aiProxy.reportService.RecordUsage(entities.AiUsage{
+		Timestamp: time.Now(),
+		Folder:    request.call.Folder,
+		Stage:     request.call.Stage,
+		Model:     request.model,
+		Tier:      request.call.Tier,
+		Vote:      request.call.Vote,
+		CacheHit:  true,
 	})
 
+	_, _, err := decodeOutput(request.schema, request.structuredOutputs, outputText, request.result)
+
+	return true, err
+}
+
+// reserveBudget holds the estimated spend of the request, to be settled once it is answered or failed.
+func (aiProxy *AiProxy) reserveBudget(request *aiRequest) error {
+
+	reservation, err := aiProxy.budget.reserve(request.model)
+	request.reservation = reservation
+
+	if err != nil {
+		aiProxy.auditService.Log(entities.Audit{
+			Severity:  entities.Warning,
+			Timestamp: time.Now(),
+			Text:      fmt.Sprintf("Request to the model '%s' for the %s of '%s' refused: %v", request.model, request.call.Stage, request.call.Folder, err)})
+	}
+
+	return err
+}
+
+// waitForGovernor waits for the turn of the request, the tokens it reserves being the average of the previous
+// requests to the model.
+func (aiProxy *AiProxy) waitForGovernor(ctx context.Context, request *aiRequest) (func(usedTokens int64), error) {
+
+	tokens := int64(defaultTokenEstimate)
+
+	if average := aiProxy.reportService.AverageUsage(request.model); average.Requests > 0 {
+		tokens = average.InputTokens + average.OutputTokens
+	}
+
+	release, waited, err := aiProxy.governor.acquire(ctx, tokens)
+
+	if waited >= time.Millisecond {
+		aiProxy.auditService.Log(entities.Audit{
+			Severity:  entities.Information,
+			Timestamp: time.Now(),
+			Text:      fmt.Sprintf("Request to the model '%s' for the %s of '%s' waited %v on the AI governor", request.model, request.call.Stage, request.call.Folder, waited.Round(time.Millisecond))})
+	}
+
+	return release, err
+}
+
+// optimizeImage downscales and re-encodes an image of the request for the upload.
+func (aiProxy *AiProxy) optimizeImage(request *aiRequest, index int, image []byte) ([]byte, string, error) {
+
+	optimizedImage, mimeType, err := aiProxy.imageOptimizer.Optimize(image)
+
 	if err != nil {
-		return "", fmt.Errorf("unable to process the prompt: %v", err)
+		return nil, "", fmt.Errorf("unable to prepare the image #%d: %w", index+1, err)
 	}
 
-	outputText := response.OutputText()
+	aiProxy.auditService.Log(entities.Audit{
+		Severity:  entities.Debug,
+		Timestamp: time.Now(),
+		Text:      fmt.Sprintf("Image #%d of the %s of '%s' optimized as %s (%d bytes, originally %d bytes)", index+1, request.call.Stage, request.call.Folder, mimeType, len(optimizedImage), len(image))})
 
-	return outputText, nil
+	return optimizedImage, mimeType, nil
 }
 
-func (aiProxy *AiProxy) SendRequestWithImage(assistantPrompt string, reader io.Reader) (string, error) {
+func (aiProxy *AiProxy) newResponseParams(ctx context.Context, request *aiRequest) (responses.ResponseNewParams, error) {
+
+	content := responses.ResponseInputMessageContentListParam{
+		{
+			OfInputText: &responses.ResponseInputTextParam{
+				Text: request.assistantPrompt,
+			},
+		},
+	}
+
+	for index, image := range request.images {
+
+		var fileID param.Opt[string]
+		var dataUrl param.Opt[string]
+
+		//	The files are uploaded to the primary provider only
+		if aiProxy.fileUploads != nil && !request.fallback {
+
+			uploadedFileID, err := aiProxy.uploadImage(ctx, request, index, image)
+
+			if err != nil {
+				return responses.ResponseNewParams{}, err
+			}
+
+			fileID = param.NewOpt(uploadedFileID)
+		} else {
+
+			optimizedImage, mimeType, err := aiProxy.optimizeImage(request, index, image)
 
-	var imageBase64StringBuilder strings.Builder
-	imageBase64StringBuilder.WriteString("data:image/jpeg;base64,")
+			if err != nil {
+				return responses.ResponseNewParams{}, err
+			}
 
-	encoder := base64.NewEncoder(base64.StdEncoding, &imageBase64StringBuilder)
+			dataUrl = param.NewOpt(fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(optimizedImage)))
+		}
 
-	if _, err := io.Copy(encoder, reader); err != nil {
-		return "", fmt.Errorf("unable to encode the image: %v", err)
+		//	The PDF pages are read by the models as documents
+		if imaging.DetectMimeType(image) == imaging.PdfMimeType {
+
+			inputFile := responses.ResponseInputFileParam{FileID: fileID, FileData: dataUrl}
+
+			if dataUrl.Valid() {
+				inputFile.Filename = param.NewOpt(fmt.Sprintf("page-%d.pdf", index+1))
+			}
+
+			content = append(content, responses.ResponseInputContentUnionParam{OfInputFile: &inputFile})
+			continue
+		}
+
+		content = append(content, responses.ResponseInputContentUnionParam{OfInputImage: &responses.ResponseInputImageParam{Type: "input_image", FileID: fileID, ImageURL: dataUrl}})
 	}
 
-	response, err := aiProxy.client.Responses.New(aiProxy.context, responses.ResponseNewParams{
+	params := responses.ResponseNewParams{
 		Input: responses.ResponseNewParamsInputUnion{
 			OfInputItemList: []responses.ResponseInputItemUnionParam{
 				{
 					OfInputMessage: &responses.ResponseInputItemMessageParam{
-						Role: "user",
-						Content: responses.ResponseInputMessageContentListParam{
-							{
-								OfInputText: &responses.ResponseInputTextParam{
-									Text: assistantPrompt,
-								},
-							},
-							{
-								OfInputImage: &responses.ResponseInputImageParam{
-									Type:     "input_image",
-									ImageURL: param.NewOpt(imageBase64StringBuilder.String()),
-								},
-							},
-						},
+						Role:    "user",
+						Content: content,
 					},
 				},
 			},
 		},
-		Model: aiProxy.imageAnalysisModel,
+		Model: request.model,
+	}
+
+	if request.reask != nil {
+		params.Input.OfInputItemList = append(params.Input.OfInputItemList,
+			responses.ResponseInputItemUnionParam{
+				OfMessage: &responses.EasyInputMessageParam{
+					Role:    responses.EasyInputMessageRoleAssistant,
+					Content: responses.EasyInputMessageContentUnionParam{OfString: param.NewOpt(request.reask.outputText)},
+				},
+			},
+			responses.ResponseInputItemUnionParam{
+				OfMessage: &responses.EasyInputMessageParam{
+					Role:    responses.EasyInputMessageRoleUser,
+					Content: responses.EasyInputMessageContentUnionParam{OfString: param.NewOpt(fmt.Sprintf(reaskPrompt, request.reask.decodeError))},
+				},
+			})
+	}
+
+	if request.reasoningEffort != "" {
+		params.Reasoning = shared.ReasoningParam{Effort: shared.ReasoningEffort(request.reasoningEffort)}
+	}
+
+	if request.maxOutputTokens > 0 {
+		params.MaxOutputTokens = param.NewOpt(int64(request.maxOutputTokens))
+	}
+
+	if request.structuredOutputs {
+		params.Text = responses.ResponseTextConfigParam{
+			Format: responses.ResponseFormatTextConfigUnionParam{
+				OfJSONSchema: &responses.ResponseFormatTextJSONSchemaConfigParam{
+					Name:   request.schema.name,
+					Schema: request.schema.schema,
+					Strict: param.NewOpt(true),
+				},
+			},
+		}
+	}
+
+	return params, nil
+}
+
+// complete accounts for the response of the request, decodes it and caches it. An answer that cannot be decoded,
+// even once repaired, is asked again once.
+func (aiProxy *AiProxy) complete(ctx context.Context, request *aiRequest, response *responses.Response, batch bool) error {
+
+	var (
+		outputText  = response.OutputText()
+		decodedText string
+		repairs     []string
+		decodeErr   error
+	)
+
+	//	Typically when the reasoning used up the maximum output tokens of the task
+	incomplete := response.Status == responses.ResponseStatusIncomplete
+
+	if !incomplete {
+		decodedText, repairs, decodeErr = decodeOutput(request.schema, request.structuredOutputs, outputText, request.result)
+	}
+
+	var usage entities.AiUsage
+
+	request.reservation.settleWith(func() {
+		usage = aiProxy.reportService.RecordUsage(entities.AiUsage{
+			Timestamp:         time.Now(),
+			Folder:            request.call.Folder,
+			Stage:             request.call.Stage,
+			Model:             request.model,
+			Tier:              request.call.Tier,
+			Vote:              request.call.Vote,
+			Provider:          aiProxy.providerOf(request),
+			Batch:             batch,
+			Reask:             request.reask != nil,
+			Repairs:           repairs,
+			InputTokens:       response.Usage.InputTokens,
+			CachedInputTokens: response.Usage.InputTokensDetails.CachedTokens,
+			OutputTokens:      response.Usage.OutputTokens,
+			ReasoningTokens:   response.Usage.OutputTokensDetails.ReasoningTokens,
+		})
 	})
 
-	if err != nil {
-		return "", fmt.Errorf("unable to process the prompt: %v", err)
+	aiProxy.auditService.Log(entities.Audit{
+		Severity:  entities.Debug,
+		Timestamp: time.Now(),
+		Text: fmt.Sprintf("Request to the model '%s' of %s for the %s of '%s' used %d input (%d cached) and %d output tokens, costing %.6f",
+			request.model, usage.Provider, request.call.Stage, request.call.Folder, usage.InputTokens, usage.CachedInputTokens, usage.OutputTokens, usage.Cost)})
+
+	if incomplete {
+		return fmt.Errorf("the response of the model '%s' is incomplete: %s", request.model, response.IncompleteDetails.Reason)
+	}
+
+	if decodeErr != nil {
+		if request.reask != nil {
+			return decodeErr
+		}
+		return aiProxy.reask(ctx, request, outputText, decodeErr)
+	}
+
+	if len(repairs) > 0 {
+		aiProxy.auditService.Log(entities.Audit{
+			Severity:  entities.Warning,
+			Timestamp: time.Now(),
+			Text:      fmt.Sprintf("Response of the model '%s' for the %s of '%s' repaired (%s): %s", request.model, request.call.Stage, request.call.Folder, strings.Join(repairs, ", "), outputText)})
+	}
+
+	//	Only the responses that could be decoded are worth caching, once repaired
+	if aiProxy.cache != nil {
+		if err := aiProxy.cache.Put(request.cacheKey, request.model, decodedText); err != nil {
+			aiProxy.auditService.Log(entities.Audit{
+				Severity:  entities.Warning,
+				Timestamp: time.Now(),
+				Text:      fmt.Sprintf("Unable to cache the response of the model '%s': %v", request.model, err)})
+		}
+	}
+
+	return nil
+}
+
+// Close releases the resources of the proxy and logs its statistics.
+func (aiProxy *AiProxy) Close() {
+
+	requests, totalWait, longestWait := aiProxy.governor.statistics()
+
+	aiProxy.auditService.Log(entities.Audit{
+		Severity:  entities.Information,
+		Timestamp: time.Now(),
+		Text:      fmt.Sprintf("AI governor: %d request(s) waited %v in total, %v at most", requests, totalWait.Round(time.Millisecond), longestWait.Round(time.Millisecond))})
+
+	if aiProxy.fileUploads != nil {
+		aiProxy.deleteUploadedFiles()
+	}
+
+	if aiProxy.cache != nil {
+		hits, misses := aiProxy.cache.Statistics()
+
+		aiProxy.auditService.Log(entities.Audit{
+			Severity:  entities.Information,
+			Timestamp: time.Now(),
+			Text:      fmt.Sprintf("AI cache: %d hit(s), %d miss(es)", hits, misses)})
+	}
+}
+
+// decodeOutput decodes the output text of a response into result, repairing it when it cannot be decoded as is.
+// It returns the text decoded and the repairs applied.
+func decodeOutput(schema *outputSchema, structuredOutputs bool, outputText string, result any) (string, []string, error) {
+
+	decode := func(text string) error {
+
+		//	A failed attempt may have decoded part of the result
+		reflect.ValueOf(result).Elem().SetZero()
+
+		if structuredOutputs {
+			return schema.decode(text, result)
+		}
+		return json.Unmarshal([]byte(text), result)
 	}
 
-	outputText := response.OutputText()
+	err := decode(outputText)
+
+	if err == nil {
+		return outputText, nil, nil
+	}
+
+	if repairedText, repairs, found := repairOutput(outputText); found && decode(repairedText) == nil {
+		return repairedText, repairs, nil
+	}
 
-	return outputText, nil
+	return "", nil, fmt.Errorf("unable to decode the response '%s': %v", outputText, err)
 }
@@ -0,0 +1,188 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"organizer/internal/abstractions"
+	"organizer/internal/abstractions/entities"
+	"organizer/internal/configuration"
+)
+
+func TestCallTimeoutBoundsTheRetries(t *testing.T) {
+
+	//	Every attempt stalls until it times out, and is retried. The body is read for the server to notice when the
+	//	client gives up.
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		io.Copy(io.Discard, request.Body)
+		<-request.Context().Done()
+	}))
+	defer server.Close()
+
+	aiProxy := newTestProxy(t, server.URL, map[string]string{
+		configuration.AiMaxAttemptsEnvVarName:    "100",
+		configuration.AiRequestTimeoutEnvVarName: "50ms",
+		configuration.AiCallTimeoutEnvVarName:    "300ms",
+	})
+
+	start := time.Now()
+
+	var answer testAnswer
+
+	err := aiProxy.SendRequest(context.Background(), entities.AiCall{Folder: "folder", Stage: entities.PageOrderingStage}, "Give the title", &answer)
+
+	if err == nil {
+		t.Fatal("expected the call to time out")
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the call to be abandoned after its timeout, it lasted %v", elapsed)
+	}
+}
+
+// failoverServers returns a primary provider always failing and a fallback provider answering, counting its calls.
+func failoverServers(t *testing.T) (primary *httptest.Server, fallback *httptest.Server, fallbackCalls *int) {
+
+	primary = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		http.Error(writer, `{"error":{"message":"overloaded","type":"server_error"}}`, http.StatusInternalServerError)
+	}))
+	t.Cleanup(primary.Close)
+
+	fallbackCalls = new(int)
+
+	fallback = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		*fallbackCalls++
+		writer.Header().Set("Content-Type", "application/json")
+		io.WriteString(writer, responseBody(`{"title":"Tilt"}`))
+	}))
+	t.Cleanup(fallback.Close)
+
+	return primary, fallback, fallbackCalls
+}
+
+func TestFailoverTradesTheReservationOfThePrimary(t *testing.T) {
+
+	primary, fallback, fallbackCalls := failoverServers(t)
+
+	//	The budget holds a single estimate, which the primary gives way to the fallback
+	aiProxy := newTestProxy(t, primary.URL, map[string]string{
+		configuration.AiFallbackBaseUrlEnvVarName:   fallback.URL,
+		configuration.AiFailoverThresholdEnvVarName: "1",
+		configuration.AiBudgetTokensEnvVarName:      strconv.Itoa(defaultTokenEstimate),
+	})
+
+	var answer testAnswer
+
+	if err := aiProxy.SendRequest(context.Background(), entities.AiCall{Folder: "folder", Stage: entities.PageOrderingStage}, "Give the title", &answer); err != nil || *fallbackCalls != 1 {
+		t.Fatalf("expected the fallback provider to answer, got %v after %d call(s)", err, *fallbackCalls)
+	}
+
+	if aiProxy.BudgetExhausted() || aiProxy.budget.outstandingTokens != 0 {
+		t.Errorf("expected the budget to be left with no reservation, got %d token(s) held, exhausted: %t", aiProxy.budget.outstandingTokens, aiProxy.BudgetExhausted())
+	}
+}
+
+func TestBudgetExhaustedAtFailoverIsRecognized(t *testing.T) {
+
+	primary, fallback, fallbackCalls := failoverServers(t)
+
+	//	The budget allows an estimate of the primary model, not one of the pricier fallback model
+	aiProxy := newTestProxy(t, primary.URL, map[string]string{
+		configuration.AiFallbackBaseUrlEnvVarName:   fallback.URL,
+		configuration.AiFallbackTextModelEnvVarName: "gpt-5",
+		configuration.AiFailoverThresholdEnvVarName: "1",
+		configuration.AiBudgetCostEnvVarName:        "0.001",
+	})
+
+	var answer testAnswer
+
+	err := aiProxy.SendRequest(context.Background(), entities.AiCall{Folder: "folder", Stage: entities.PageOrderingStage}, "Give the title", &answer)
+
+	if !errors.Is(err, abstractions.ErrBudgetExhausted) || *fallbackCalls != 0 {
+		t.Errorf("expected the failover to be refused by the budget, got %v after %d call(s) to the fallback provider", err, *fallbackCalls)
+	}
+}
+
+func TestLocalFailuresDoNotFailOver(t *testing.T) {
+
+	primary, fallback, fallbackCalls := failoverServers(t)
+
+	aiProxy := newTestProxy(t, primary.URL, map[string]string{
+		configuration.AiFallbackBaseUrlEnvVarName:   fallback.URL,
+		configuration.AiFailoverThresholdEnvVarName: "1",
+	})
+
+	var answer testAnswer
+
+	//	The scan cannot be decoded, whichever provider reads it
+	err := aiProxy.SendRequestWithImage(context.Background(), entities.AiCall{Folder: "folder", Stage: entities.CoverAnalysisStage}, "Give the title", strings.NewReader("not an image"), &answer)
+
+	if err == nil || *fallbackCalls != 0 {
+		t.Errorf("expected the corrupt scan to fail without failing over, got %v after %d call(s) to the fallback provider", err, *fallbackCalls)
+	}
+}
+
+func TestAttemptsSurviveTheProxyErrors(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		http.Error(writer, `{"error":{"message":"overloaded","type":"server_error"}}`, http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	aiProxy := newTestProxy(t, server.URL, map[string]string{configuration.AiMaxAttemptsEnvVarName: "2"})
+	aiProxy.retryPolicy.baseDelay = time.Millisecond
+
+	var answer testAnswer
+
+	err := aiProxy.SendRequest(context.Background(), entities.AiCall{Folder: "folder", Stage: entities.PageOrderingStage}, "Give the title", &answer)
+
+	if attempts := abstractions.AttemptsOf(err); attempts != 2 {
+		t.Errorf("expected the failure to tell the 2 attempts made, got %d: %v", attempts, err)
+	}
+}
+
+func TestPdfPagesAreSentAsFiles(t *testing.T) {
+
+	var body struct {
+		Input []struct {
+			Content []struct {
+				Type     string `json:"type"`
+				FileData string `json:"file_data"`
+				Filename string `json:"filename"`
+			} `json:"content"`
+		} `json:"input"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		json.NewDecoder(request.Body).Decode(&body)
+		writer.Header().Set("Content-Type", "application/json")
+		io.WriteString(writer, responseBody(`{"title":"Tilt"}`))
+	}))
+	defer server.Close()
+
+	aiProxy := newTestProxy(t, server.URL, nil)
+
+	var answer testAnswer
+
+	if err := aiProxy.SendRequestWithImage(context.Background(), entities.AiCall{Folder: "folder", Stage: entities.CoverAnalysisStage}, "Give the title", strings.NewReader("%PDF-1.7\n"), &answer); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(body.Input) != 1 || len(body.Input[0].Content) != 2 {
+		t.Fatalf("expected the prompt and the page, got %+v", body.Input)
+	}
+
+	page := body.Input[0].Content[1]
+
+	if page.Type != "input_file" || !strings.HasPrefix(page.FileData, "data:application/pdf;base64,") || page.Filename != "page-1.pdf" {
+		t.Errorf("expected the PDF page to be sent as a file, got %+v", page)
+	}
+}
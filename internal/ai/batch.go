@@ -0,0 +1,368 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"organizer/internal/abstractions/entities"
+	"organizer/internal/abstractions/interfaces"
+
+	openai "github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/responses"
+)
+
+const (
+	batchFileContentType = "application/jsonl"
+)
+
+// batchInputLine is a request of the JSONL file submitted to the Batch API.
+type batchInputLine struct {
+	CustomID string                      `json:"custom_id"`
+	Method   string                      `json:"method"`
+	URL      string                      `json:"url"`
+	Body     responses.ResponseNewParams `json:"body"`
+}
+
+// batchOutputLine is a response, or an error, of the JSONL files produced by the Batch API.
+type batchOutputLine struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int             `json:"status_code"`
+		Body       json.RawMessage `json:"body"`
+	} `json:"response"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// aiBatch writes the requests to a JSONL file as they are added, then submits it to the Batch API.
+type aiBatch struct {
+	aiProxy   *AiProxy
+	file      *os.File
+	encoder   *json.Encoder
+	requests  []*aiRequest
+	errors    []error
+	answered  []bool
+	customIDs map[string]int
+	//	Files of the batch stored by the Files API, deleted once the batch is settled
+	fileIDs []string
+}
+
+// NewBatch starts a batch whose requests are written to a temporary JSONL file, removed once uploaded.
+func (aiProxy *AiProxy) NewBatch() (interfaces.AiBatch, error) {
+
+	file, err := os.CreateTemp("", "batch-*.jsonl")
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the batch file: %v", err)
+	}
+
+	return &aiBatch{
+		aiProxy:   aiProxy,
+		file:      file,
+		encoder:   json.NewEncoder(file),
+		customIDs: make(map[string]int),
+	}, nil
+}
+
+func (b *aiBatch) Add(ctx context.Context, call entities.AiCall, assistantPrompt string, readers []io.Reader, result any) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	images, err := readImages(readers)
+
+	if err != nil {
+		return err
+	}
+
+	request, err := b.aiProxy.newRequest(call, assistantPrompt, images, result)
+
+	if err != nil {
+		return err
+	}
+
+	//	A cached request is answered right away and does not go through the batch
+	if found, err := b.aiProxy.answerFromCache(request); found {
+		b.add(request, true, err)
+		return nil
+	}
+
+	params, err := b.aiProxy.newResponseParams(ctx, request)
+
+	if err != nil {
+		return err
+	}
+
+	//	Reserved once the request is built, and released when it cannot be written since Run never settles it
+	if err := b.aiProxy.reserveBudget(request); err != nil {
+		return err
+	}
+
+	customID := fmt.Sprintf("request-%d", len(b.requests)+1)
+
+	if err := b.encoder.Encode(batchInputLine{CustomID: customID, Method: "POST", URL: string(openai.BatchNewParamsEndpointV1Responses), Body: params}); err != nil {
+		request.reservation.settle()
+		return fmt.Errorf("unable to write the request to the batch file %s: %v", b.file.Name(), err)
+	}
+
+	b.customIDs[customID] = len(b.requests)
+	b.add(request, false, nil)
+
+	return nil
+}
+
+func (b *aiBatch) add(request *aiRequest, answered bool, err error) {
+	b.requests = append(b.requests, request)
+	b.answered = append(b.answered, answered)
+	b.errors = append(b.errors, err)
+}
+
+func (b *aiBatch) Run(ctx context.Context) ([]error, error) {
+
+	//	The spend of the requests stays reserved until the batch is over, answered or not
+	defer func() {
+		for _, request := range b.requests {
+			request.reservation.settle()
+		}
+	}()
+
+	inputFile, err := b.upload(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	//	Nothing to submit, the requests were all answered from the cache
+	if inputFile == nil {
+		return b.errors, nil
+	}
+
+	defer b.deleteFiles()
+
+	aiProxy := b.aiProxy
+
+	var batch *openai.Batch
+
+	err = aiProxy.withRetries(ctx, "Creation of the batch", func() error {
+		batch, err = aiProxy.client.Batches.New(ctx, openai.BatchNewParams{
+			CompletionWindow: openai.BatchNewParamsCompletionWindow24h,
+			Endpoint:         openai.BatchNewParamsEndpointV1Responses,
+			InputFileID:      inputFile.ID,
+		})
+		return err
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the batch: %v", err)
+	}
+
+	aiProxy.auditService.Log(entities.Audit{
+		Severity:  entities.Information,
+		Timestamp: time.Now(),
+		Text:      fmt.Sprintf("Batch %s submitted with %d request(s) from the file %s", batch.ID, len(b.customIDs), inputFile.ID)})
+
+	batch, err = b.waitForCompletion(ctx, batch)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fileID := range []string{batch.OutputFileID, batch.ErrorFileID} {
+		if fileID == "" {
+			continue
+		}
+		b.fileIDs = append(b.fileIDs, fileID)
+		if err := b.readOutputFile(ctx, fileID); err != nil {
+			return nil, err
+		}
+	}
+
+	for index, answered := range b.answered {
+		if !answered {
+			b.errors[index] = fmt.Errorf("the batch %s ended with the status '%s' without answering the request", batch.ID, batch.Status)
+		}
+	}
+
+	return b.errors, nil
+}
+
+// upload uploads the requests of the batch, the file being removed whether it is uploaded or not. It returns no file
+// when the batch has no request.
+func (b *aiBatch) upload(ctx context.Context) (*openai.FileObject, error) {
+
+	defer os.Remove(b.file.Name())
+
+	if err := b.file.Close(); err != nil {
+		return nil, fmt.Errorf("unable to write the batch file %s: %v", b.file.Name(), err)
+	}
+
+	if len(b.customIDs) == 0 {
+		return nil, nil
+	}
+
+	aiProxy := b.aiProxy
+
+	var inputFile *openai.FileObject
+
+	err := aiProxy.withRetries(ctx, "Upload of the batch file", func() error {
+
+		reader, err := os.Open(b.file.Name())
+
+		if err != nil {
+			return err
+		}
+
+		defer reader.Close()
+
+		inputFile, err = aiProxy.client.Files.New(ctx, openai.FileNewParams{
+			File:    openai.File(reader, filepath.Base(b.file.Name()), batchFileContentType),
+			Purpose: openai.FilePurposeBatch,
+		})
+
+		return err
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to upload the batch file %s: %v", b.file.Name(), err)
+	}
+
+	b.fileIDs = append(b.fileIDs, inputFile.ID)
+
+	return inputFile, nil
+}
+
+// deleteFiles deletes the input and output files of the batch from the Files API.
+func (b *aiBatch) deleteFiles() {
+
+	aiProxy := b.aiProxy
+
+	//	The run context may be cancelled already
+	ctx, cancel := context.WithTimeout(context.Background(), fileCleanupTimeout)
+	defer cancel()
+
+	for _, fileID := range b.fileIDs {
+		if _, err := aiProxy.client.Files.Delete(ctx, fileID); err != nil {
+			aiProxy.auditService.Log(entities.Audit{
+				Severity:  entities.Warning,
+				Timestamp: time.Now(),
+				Text:      fmt.Sprintf("Unable to delete the batch file %s: %v", fileID, err)})
+		}
+	}
+}
+
+// waitForCompletion polls the batch until it reaches a final status.
+func (b *aiBatch) waitForCompletion(ctx context.Context, batch *openai.Batch) (*openai.Batch, error) {
+
+	aiProxy := b.aiProxy
+	status := batch.Status
+
+	for {
+		switch batch.Status {
+		case openai.BatchStatusCompleted, openai.BatchStatusFailed, openai.BatchStatusExpired, openai.BatchStatusCancelled:
+			aiProxy.auditService.Log(entities.Audit{
+				Severity:  entities.Information,
+				Timestamp: time.Now(),
+				Text: fmt.Sprintf("Batch %s ended with the status '%s': %d completed, %d failed",
+					batch.ID, batch.Status, batch.RequestCounts.Completed, batch.RequestCounts.Failed)})
+			return batch, nil
+		}
+
+		select {
+		case <-time.After(aiProxy.batchPollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		var err error
+
+		err = aiProxy.withRetries(ctx, "Polling of the batch", func() error {
+			batch, err = aiProxy.client.Batches.Get(ctx, batch.ID)
+			return err
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to retrieve the status of the batch: %v", err)
+		}
+
+		if batch.Status != status {
+			status = batch.Status
+			aiProxy.auditService.Log(entities.Audit{
+				Severity:  entities.Information,
+				Timestamp: time.Now(),
+				Text: fmt.Sprintf("Batch %s is now '%s': %d/%d request(s) completed",
+					batch.ID, batch.Status, batch.RequestCounts.Completed, batch.RequestCounts.Total)})
+		}
+	}
+}
+
+// readOutputFile decodes the responses, or the errors, of an output file of the batch.
+func (b *aiBatch) readOutputFile(ctx context.Context, fileID string) error {
+
+	aiProxy := b.aiProxy
+
+	content, err := aiProxy.client.Files.Content(ctx, fileID)
+
+	if err != nil {
+		return fmt.Errorf("unable to download the batch output file %s: %v", fileID, err)
+	}
+
+	defer content.Body.Close()
+
+	decoder := json.NewDecoder(content.Body)
+
+	for {
+		var line batchOutputLine
+
+		if err := decoder.Decode(&line); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("unable to decode the batch output file %s: %v", fileID, err)
+		}
+
+		index, found := b.customIDs[line.CustomID]
+
+		if !found || b.answered[index] {
+			continue
+		}
+
+		b.answered[index] = true
+		b.errors[index] = b.complete(ctx, b.requests[index], line)
+	}
+}
+
+// complete decodes the response of a batch request, an answer that cannot be decoded being asked again in real time.
+func (b *aiBatch) complete(ctx context.Context, request *aiRequest, line batchOutputLine) error {
+
+	if line.Error != nil {
+		return fmt.Errorf("the batch request failed with the code '%s': %s", line.Error.Code, line.Error.Message)
+	}
+
+	if line.Response == nil {
+		return fmt.Errorf("the batch request has no response")
+	}
+
+	if line.Response.StatusCode != 200 {
+		return fmt.Errorf("the batch request failed with the status %d: %s", line.Response.StatusCode, line.Response.Body)
+	}
+
+	var response responses.Response
+
+	if err := json.Unmarshal(line.Response.Body, &response); err != nil {
+		return fmt.Errorf("unable to decode the batch response: %v", err)
+	}
+
+	//	The answer was waited for in the batch, only its reask is bounded like a call
+	ctx, cancel := b.aiProxy.withCallTimeout(ctx)
+	defer cancel()
+
+	return b.aiProxy.complete(ctx, request, &response, true)
+}
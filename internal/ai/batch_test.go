@@ -0,0 +1,244 @@
+package ai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"organizer/internal/abstractions/entities"
+	"organizer/internal/audit"
+	"organizer/internal/configuration"
+	"organizer/internal/report"
+)
+
+// testAnswer is the answer expected from the model in the tests.
+type testAnswer struct {
+	Title string `json:"title"`
+}
+
+// newTestProxy returns a proxy of the OpenAI-compatible server at the base URL, its audit log, reports and batch
+// files being written to a temporary directory.
+func newTestProxy(t *testing.T, baseUrl string, environment map[string]string) *AiProxy {
+
+	t.Chdir(t.TempDir())
+
+	t.Setenv(configuration.OpenaiBaseUrlEnvVarName, baseUrl)
+	t.Setenv(configuration.OpenaiApiKeyEnvVarName, "test")
+	t.Setenv(configuration.WorkingDirectoryEnvVarName, ".")
+	t.Setenv(configuration.AiMaxAttemptsEnvVarName, "1")
+
+	for name, value := range environment {
+		t.Setenv(name, value)
+	}
+
+	configurationService, err := configuration.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auditService, err := audit.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aiProxy, err := New(configurationService, auditService, report.New(configurationService))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return aiProxy
+}
+
+// responseBody returns a completed response of the Responses API answering the output text.
+func responseBody(outputText string) string {
+
+	text, _ := json.Marshal(outputText)
+
+	return fmt.Sprintf(`{"id":"resp_test","object":"response","created_at":0,"model":"gpt-5-nano","status":"completed",`+
+		`"output":[{"type":"message","id":"msg_test","role":"assistant","status":"completed","content":[{"type":"output_text","text":%s,"annotations":[]}]}],`+
+		`"usage":{"input_tokens":10,"input_tokens_details":{"cached_tokens":0},"output_tokens":5,"output_tokens_details":{"reasoning_tokens":0},"total_tokens":15}}`, text)
+}
+
+// batchServer mimics the files and batches endpoints of the Batch API, answering the requests of the input file
+// through the given function: an empty answer leaves the request unanswered, an answer starting with 'error:'
+// is written to the error file.
+type batchServer struct {
+	mutex    sync.Mutex
+	answer   func(customID string) string
+	inputIDs []string
+	reasks   int
+	deleted  []string
+}
+
+func (s *batchServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	writer.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case request.Method == http.MethodPost && request.URL.Path == "/files":
+		file, _, err := request.FormFile("file")
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		for scanner := bufio.NewScanner(file); scanner.Scan(); {
+			var line batchInputLine
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				http.Error(writer, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.inputIDs = append(s.inputIDs, line.CustomID)
+		}
+
+		fmt.Fprint(writer, `{"id":"file-input","object":"file","bytes":1,"created_at":0,"filename":"batch.jsonl","purpose":"batch","status":"processed"}`)
+
+	case request.Method == http.MethodPost && request.URL.Path == "/batches":
+		fmt.Fprint(writer, batchBody("in_progress"))
+
+	case request.Method == http.MethodGet && request.URL.Path == "/batches/batch_test":
+		fmt.Fprint(writer, batchBody("completed"))
+
+	case request.Method == http.MethodGet && (request.URL.Path == "/files/file-output/content" || request.URL.Path == "/files/file-error/content"):
+		for _, customID := range s.inputIDs {
+
+			answer := s.answer(customID)
+			isError := strings.HasPrefix(answer, "error:")
+
+			switch {
+			case answer == "", isError != strings.Contains(request.URL.Path, "error"):
+				//	Unanswered, or written to the other file
+			case isError:
+				fmt.Fprintf(writer, `{"custom_id":%q,"response":null,"error":{"code":"invalid_request","message":%q}}`+"\n", customID, strings.TrimPrefix(answer, "error:"))
+			default:
+				fmt.Fprintf(writer, `{"custom_id":%q,"response":{"status_code":200,"body":%s},"error":null}`+"\n", customID, responseBody(answer))
+			}
+		}
+
+	case request.Method == http.MethodDelete && strings.HasPrefix(request.URL.Path, "/files/"):
+		fileID := strings.TrimPrefix(request.URL.Path, "/files/")
+		s.deleted = append(s.deleted, fileID)
+		fmt.Fprintf(writer, `{"id":%q,"object":"file","deleted":true}`, fileID)
+
+	case request.Method == http.MethodPost && request.URL.Path == "/responses":
+		//	The answers that could not be decoded are asked again in real time, and still cannot be
+		s.reasks++
+		fmt.Fprint(writer, responseBody("still not JSON"))
+
+	default:
+		http.NotFound(writer, request)
+	}
+}
+
+func batchBody(status string) string {
+	return fmt.Sprintf(`{"id":"batch_test","object":"batch","endpoint":"/v1/responses","input_file_id":"file-input","completion_window":"24h",`+
+		`"status":%q,"output_file_id":"file-output","error_file_id":"file-error","created_at":0,"request_counts":{"total":4,"completed":2,"failed":1}}`, status)
+}
+
+func TestBatchAnswersItsRequests(t *testing.T) {
+
+	answers := map[string]string{
+		"request-1": `{"title":"Joystick"}`,
+		"request-2": `not JSON at all`,
+		"request-3": "",
+		"request-4": "error:the image is too large",
+	}
+
+	server := &batchServer{answer: func(customID string) string { return answers[customID] }}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	temporaryDirectory := t.TempDir()
+	t.Setenv("TMPDIR", temporaryDirectory)
+
+	aiProxy := newTestProxy(t, httpServer.URL, map[string]string{configuration.AiBatchPollIntervalEnvVarName: "1ms"})
+
+	batch, err := aiProxy.NewBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := make([]testAnswer, len(answers))
+
+	for index := range results {
+		call := entities.AiCall{Folder: fmt.Sprintf("folder %d", index+1), Stage: entities.PageOrderingStage}
+		if err := batch.Add(context.Background(), call, "Give the title", nil, &results[index]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	errs, err := batch.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errs[0] != nil || results[0].Title != "Joystick" {
+		t.Errorf("expected the first request to be answered, got %+v, %v", results[0], errs[0])
+	}
+
+	if errs[1] == nil || server.reasks != 1 {
+		t.Errorf("expected the undecodable answer to be asked again once and to fail, got %v after %d reask(s)", errs[1], server.reasks)
+	}
+
+	if errs[2] == nil || !strings.Contains(errs[2].Error(), "without answering") {
+		t.Errorf("expected the unanswered request to fail, got %v", errs[2])
+	}
+
+	if errs[3] == nil || !strings.Contains(errs[3].Error(), "invalid_request") {
+		t.Errorf("expected the request of the error file to fail with its code, got %v", errs[3])
+	}
+
+	if totals := aiProxy.reportService.Totals(); totals.Requests != 3 || totals.Reasks != 1 {
+		t.Errorf("expected 2 batch answers and 1 reask accounted, got %d request(s) and %d reask(s)", totals.Requests, totals.Reasks)
+	}
+
+	if files, _ := os.ReadDir(temporaryDirectory); len(files) != 0 {
+		t.Errorf("expected the batch file to be removed once uploaded, found %d file(s)", len(files))
+	}
+
+	slices.Sort(server.deleted)
+
+	if expected := []string{"file-error", "file-input", "file-output"}; !slices.Equal(server.deleted, expected) {
+		t.Errorf("expected the files %v to be deleted from the Files API, got %v", expected, server.deleted)
+	}
+}
+
+func TestBatchReleasesTheBudgetOfTheRequestsNotAdded(t *testing.T) {
+
+	aiProxy := newTestProxy(t, "http://127.0.0.1:0", map[string]string{
+		configuration.AiBudgetTokensEnvVarName: strconv.Itoa(defaultTokenEstimate),
+	})
+
+	batch, err := aiProxy.NewBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call := entities.AiCall{Folder: "folder", Stage: entities.CoverAnalysisStage}
+
+	//	A scan the optimizer cannot decode never makes it to the batch
+	if err := batch.Add(context.Background(), call, "Give the title", []io.Reader{strings.NewReader("not an image")}, &testAnswer{}); err == nil {
+		t.Fatal("expected the corrupt scan to be refused")
+	}
+
+	if aiProxy.budget.outstandingTokens != 0 {
+		t.Errorf("expected no spend to be held for the request not added, got %d token(s)", aiProxy.budget.outstandingTokens)
+	}
+
+	if err := batch.Add(context.Background(), entities.AiCall{Folder: "folder", Stage: entities.PageOrderingStage}, "Give the title", nil, &testAnswer{}); err != nil {
+		t.Errorf("expected the budget to still hold the next request, got %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package ai
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"organizer/internal/abstractions"
+	"organizer/internal/abstractions/entities"
+	"organizer/internal/report"
+)
+
+// budget refuses the requests that would make the run spend more than allowed. Since a request
+// cost is only known once answered, it is estimated from the average of the previous requests
+// made to the same model, or from a default estimate before the model answered. The estimate is
+// held by the budget until the request is answered or fails, so that the requests in flight and
+// the requests waiting in a batch are accounted for.
+type budget struct {
+	maxTokens         int64
+	maxCost           float64
+	reportService     *report.ReportService
+	exhausted         atomic.Bool
+	mutex             sync.Mutex
+	outstandingTokens int64
+	outstandingCost   float64
+}
+
+// budgetReservation is the estimated spend of a request, held until the request is settled.
+type budgetReservation struct {
+	budget  *budget
+	tokens  int64
+	cost    float64
+	settled bool
+}
+
+func (b *budget) enabled() bool {
+	return b.maxTokens > 0 || b.maxCost > 0
+}
+
+// reserve holds the estimated spend of the next request to the model, or returns
+// abstractions.ErrBudgetExhausted when it would exceed the budget. Once exhausted, the budget
+// refuses every request. No reservation is returned when the budget is disabled.
+func (b *budget) reserve(model string) (*budgetReservation, error) {
+
+	if !b.enabled() {
+		return nil, nil
+	}
+
+	if b.exhausted.Load() {
+		return nil, abstractions.ErrBudgetExhausted
+	}
+
+	estimate := b.estimate(model)
+	estimatedTokens := estimate.InputTokens + estimate.OutputTokens
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	totals := b.reportService.Totals()
+
+	spentTokens := totals.InputTokens + totals.OutputTokens + b.outstandingTokens
+	spentCost := totals.Cost + b.outstandingCost
+
+	var reason string
+
+	switch {
+	case b.maxTokens > 0 && spentTokens+estimatedTokens > b.maxTokens:
+		reason = fmt.Sprintf("%d tokens spent or reserved, about %d more needed, %d allowed", spentTokens, estimatedTokens, b.maxTokens)
+	case b.maxCost > 0 && spentCost+estimate.Cost > b.maxCost:
+		reason = fmt.Sprintf("%.4f spent or reserved, about %.4f more needed, %.4f allowed", spentCost, estimate.Cost, b.maxCost)
+	default:
+		b.outstandingTokens += estimatedTokens
+		b.outstandingCost += estimate.Cost
+
+		return &budgetReservation{budget: b, tokens: estimatedTokens, cost: estimate.Cost}, nil
+	}
+
+	if b.exhausted.CompareAndSwap(false, true) {
+		b.reportService.RecordBudgetExhausted(reason)
+	}
+
+	return nil, abstractions.ErrBudgetExhausted
+}
+
+// estimate returns the expected usage of a request to the model.
+func (b *budget) estimate(model string) report.UsageTotals {
+
+	if average := b.reportService.AverageUsage(model); average.Requests > 0 {
+		return average
+	}
+
+	usage := entities.AiUsage{Model: model, InputTokens: defaultTokenEstimate / 2, OutputTokens: defaultTokenEstimate / 2}
+
+	return report.UsageTotals{
+		Requests:     1,
+		InputTokens:  usage.InputTokens,
+		OutputTokens: usage.OutputTokens,
+		Cost:         b.reportService.Price(usage),
+	}
+}
+
+// settleWith records the usage of the answered request and releases its reservation at once, so that a concurrent
+// reservation never sees its spend counted twice nor missed.
+func (r *budgetReservation) settleWith(record func()) {
+
+	if r == nil {
+		record()
+		return
+	}
+
+	r.budget.mutex.Lock()
+	defer r.budget.mutex.Unlock()
+
+	record()
+
+	if !r.settled {
+		r.settled = true
+		r.budget.outstandingTokens -= r.tokens
+		r.budget.outstandingCost -= r.cost
+	}
+}
+
+// settle releases the reservation once the request is answered, its usage being accounted by the
+// report from then on, or once it failed. Settling twice, or no reservation, does nothing.
+func (r *budgetReservation) settle() {
+	r.settleWith(func() {})
+}
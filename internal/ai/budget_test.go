@@ -0,0 +1,63 @@
+package ai
+
+import (
+	"errors"
+	"testing"
+
+	"organizer/internal/abstractions"
+	"organizer/internal/abstractions/entities"
+	"organizer/internal/configuration"
+	"organizer/internal/report"
+)
+
+func TestBudgetReservesTheRequestsInFlight(t *testing.T) {
+
+	reportService := report.New(&configuration.ConfigurationService{})
+	budget := &budget{maxTokens: 2500, reportService: reportService}
+
+	//	Before the model answered, the default estimate is reserved
+	first, err := budget.reserve("gpt-5-nano")
+	if err != nil || first == nil || first.tokens != defaultTokenEstimate {
+		t.Fatalf("expected a reservation of %d tokens, got %+v, %v", defaultTokenEstimate, first, err)
+	}
+
+	second, err := budget.reserve("gpt-5-nano")
+	if err != nil {
+		t.Fatalf("expected the second request to fit in the budget: %v", err)
+	}
+
+	//	Both requests are still in flight
+	if _, err := budget.reserve("gpt-5-nano"); !errors.Is(err, abstractions.ErrBudgetExhausted) {
+		t.Fatalf("expected the third request to be refused, got %v", err)
+	}
+
+	first.settle()
+	first.settle()
+	second.settle()
+
+	if budget.outstandingTokens != 0 || budget.outstandingCost != 0 {
+		t.Errorf("expected nothing outstanding once settled, got %d tokens and %f", budget.outstandingTokens, budget.outstandingCost)
+	}
+}
+
+func TestBudgetEstimatesFromTheAnsweredRequests(t *testing.T) {
+
+	reportService := report.New(&configuration.ConfigurationService{})
+	costBudget := &budget{maxCost: 1, reportService: reportService}
+
+	if estimate := costBudget.estimate("gpt-5"); estimate.Cost <= 0 {
+		t.Errorf("expected a priced default estimate, got %f", estimate.Cost)
+	}
+
+	reportService.RecordUsage(entities.AiUsage{Model: "gpt-5", InputTokens: 10_000, OutputTokens: 30_000})
+
+	if estimate := costBudget.estimate("gpt-5"); estimate.InputTokens != 10_000 || estimate.OutputTokens != 30_000 {
+		t.Errorf("expected the average of the answered requests, got %+v", estimate)
+	}
+
+	budgetless := &budget{reportService: reportService}
+
+	if reservation, err := budgetless.reserve("gpt-5"); reservation != nil || err != nil {
+		t.Errorf("expected no reservation without a budget, got %+v, %v", reservation, err)
+	}
+}
@@ -0,0 +1,255 @@
+package ai
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"organizer/internal/configuration"
+)
+
+const (
+	cassetteExtension = ".json"
+)
+
+// recordedHeaders are the response headers kept in the cassettes; the others may identify the account.
+var recordedHeaders = []string{"Content-Type", "Retry-After", "Retry-After-Ms", "X-Request-Id"}
+
+// cassetteInteraction is an HTTP exchange recorded in a cassette.
+type cassetteInteraction struct {
+	Request  cassetteRequest  `json:"request"`
+	Response cassetteResponse `json:"response"`
+}
+
+type cassetteRequest struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Body   string `json:"body"`
+}
+
+type cassetteResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+}
+
+// cassetteTransport records the HTTP exchanges of the OpenAI client into cassette files, or serves
+// them back. A cassette holds the interactions of identical requests, in the order they happened,
+// and is named after the hash of the request so that the replay does not depend on the order in
+// which the folders are processed. The credentials are never recorded.
+type cassetteTransport struct {
+	mode         string
+	directory    string
+	transport    http.RoundTripper
+	mutex        sync.Mutex
+	interactions map[string][]cassetteInteraction
+	replayed     map[string]int
+}
+
+func newCassetteTransport(mode string, directory string) (*cassetteTransport, error) {
+
+	if mode == configuration.CassetteRecordMode {
+		if err := os.MkdirAll(directory, os.ModePerm); err != nil {
+			return nil, fmt.Errorf("unable to create the cassette directory %s: %v", directory, err)
+		}
+	}
+
+	return &cassetteTransport{
+		mode:         mode,
+		directory:    directory,
+		transport:    http.DefaultTransport,
+		interactions: make(map[string][]cassetteInteraction),
+		replayed:     make(map[string]int),
+	}, nil
+}
+
+func (t *cassetteTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+
+	var body []byte
+
+	if request.Body != nil {
+
+		var err error
+
+		body, err = io.ReadAll(request.Body)
+		_ = request.Body.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the request body: %v", err)
+		}
+
+		request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	key := cassetteKey(request, body)
+
+	if t.mode == configuration.CassetteReplayMode {
+		return t.replay(request, key)
+	}
+
+	response, err := t.transport.RoundTrip(request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody, err := io.ReadAll(response.Body)
+	_ = response.Body.Close()
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the response body: %v", err)
+	}
+
+	response.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	header := make(http.Header)
+
+	for _, name := range recordedHeaders {
+		if value := response.Header.Get(name); value != "" {
+			header.Set(name, value)
+		}
+	}
+
+	interaction := cassetteInteraction{
+		Request:  cassetteRequest{Method: request.Method, Path: request.URL.RequestURI(), Body: string(body)},
+		Response: cassetteResponse{StatusCode: response.StatusCode, Header: header, Body: string(responseBody)},
+	}
+
+	if err := t.record(key, interaction); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// cassetteKey hashes the method, the path and the body of the request. The boundaries and the
+// file names of the multipart bodies vary from a run to another and are left out.
+func cassetteKey(request *http.Request, body []byte) string {
+
+	hash := sha256.New()
+
+	hash.Write([]byte(request.Method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(request.URL.RequestURI()))
+	hash.Write([]byte{0})
+
+	mediaType, params, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+
+	if err != nil || mediaType != "multipart/form-data" {
+		hash.Write(body)
+		return hex.EncodeToString(hash.Sum(nil))
+	}
+
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+
+	for {
+		part, err := reader.NextPart()
+
+		if err != nil {
+			break
+		}
+
+		hash.Write([]byte(part.FormName()))
+		hash.Write([]byte{0})
+		_, _ = io.Copy(hash, part)
+		hash.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (t *cassetteTransport) path(key string) string {
+	return filepath.Join(t.directory, key+cassetteExtension)
+}
+
+// record adds the interaction to the cassette of the request, overwriting the cassettes of a previous recording.
+func (t *cassetteTransport) record(key string, interaction cassetteInteraction) error {
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.interactions[key] = append(t.interactions[key], interaction)
+
+	content, err := json.MarshalIndent(t.interactions[key], "", "  ")
+
+	if err != nil {
+		return fmt.Errorf("unable to encode the cassette: %v", err)
+	}
+
+	if err := os.WriteFile(t.path(key), content, 0644); err != nil {
+		return fmt.Errorf("unable to write the cassette %s: %v", t.path(key), err)
+	}
+
+	return nil
+}
+
+// replay serves the next recorded interaction of the request, the last one being repeated once they
+// are all served. A request that was never recorded is answered with a 404 error so that it is not retried.
+func (t *cassetteTransport) replay(request *http.Request, key string) (*http.Response, error) {
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	interactions, loaded := t.interactions[key]
+
+	if !loaded {
+
+		content, err := os.ReadFile(t.path(key))
+
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("unable to read the cassette %s: %v", t.path(key), err)
+		}
+
+		if err == nil {
+			if err := json.Unmarshal(content, &interactions); err != nil {
+				return nil, fmt.Errorf("unable to decode the cassette %s: %v", t.path(key), err)
+			}
+		}
+
+		t.interactions[key] = interactions
+	}
+
+	if len(interactions) == 0 {
+		message := fmt.Sprintf("no interaction is recorded in %s for %s %s", t.directory, request.Method, request.URL.RequestURI())
+		content, _ := json.Marshal(map[string]any{"error": map[string]string{"message": message, "code": "cassette_miss"}})
+		return newCassetteResponse(request, http.StatusNotFound, http.Header{"Content-Type": {"application/json"}}, string(content)), nil
+	}
+
+	index := min(t.replayed[key], len(interactions)-1)
+	t.replayed[key]++
+
+	recorded := interactions[index].Response
+
+	return newCassetteResponse(request, recorded.StatusCode, recorded.Header.Clone(), recorded.Body), nil
+}
+
+func newCassetteResponse(request *http.Request, statusCode int, header http.Header, body string) *http.Response {
+
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       request,
+	}
+}
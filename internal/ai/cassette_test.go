@@ -0,0 +1,81 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"organizer/internal/abstractions/entities"
+	"organizer/internal/configuration"
+
+	openai "github.com/openai/openai-go/v3"
+)
+
+func TestCassetteRoundTrip(t *testing.T) {
+
+	directory := t.TempDir()
+	calls := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		calls++
+		writer.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(writer, responseBody(fmt.Sprintf(`{"title":"Tilt %d"}`, calls)))
+	}))
+
+	call := entities.AiCall{Folder: "folder", Stage: entities.PageOrderingStage}
+
+	recorder := newTestProxy(t, server.URL, map[string]string{
+		configuration.AiCassetteModeEnvVarName:      configuration.CassetteRecordMode,
+		configuration.AiCassetteDirectoryEnvVarName: directory,
+	})
+
+	var recorded testAnswer
+
+	if err := recorder.SendRequest(context.Background(), call, "Give the title", &recorded); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Close()
+
+	cassettes, _ := filepath.Glob(filepath.Join(directory, "*"+cassetteExtension))
+
+	if len(cassettes) != 1 {
+		t.Fatalf("expected a cassette to be recorded, got %v", cassettes)
+	}
+
+	if content, _ := os.ReadFile(cassettes[0]); strings.Contains(string(content), "Bearer") {
+		t.Error("the credentials are recorded in the cassette")
+	}
+
+	//	The server is stopped, the answers come from the cassette
+	player := newTestProxy(t, server.URL, map[string]string{
+		configuration.AiCassetteModeEnvVarName:      configuration.CassetteReplayMode,
+		configuration.AiCassetteDirectoryEnvVarName: directory,
+	})
+
+	var replayed testAnswer
+
+	if err := player.SendRequest(context.Background(), call, "Give the title", &replayed); err != nil {
+		t.Fatal(err)
+	}
+
+	if replayed != recorded || calls != 1 {
+		t.Errorf("expected the recorded answer %+v to be replayed, got %+v after %d call(s) to the server", recorded, replayed, calls)
+	}
+
+	var missed testAnswer
+
+	err := player.SendRequest(context.Background(), call, "Give the publisher", &missed)
+
+	var apiError *openai.Error
+
+	if !errors.As(err, &apiError) || apiError.Code != "cassette_miss" {
+		t.Errorf("expected a request never recorded to fail with a cassette miss, got %v", err)
+	}
+}
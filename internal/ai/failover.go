@@ -0,0 +1,125 @@
+package ai
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"organizer/internal/abstractions/entities"
+	"organizer/internal/configuration"
+
+	openai "github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+const (
+	defaultProviderName = "api.openai.com"
+)
+
+// aiFallback is the OpenAI-compatible provider, typically a local server, the requests fail over to when the
+// primary provider keeps failing.
+type aiFallback struct {
+	name       string
+	client     *openai.Client
+	textModel  string
+	imageModel string
+	threshold  int
+}
+
+func newAiFallback(configurationService *configuration.ConfigurationService, modelCapabilities map[string]ModelCapabilities, httpClient *http.Client) (*aiFallback, error) {
+
+	settings := configurationService.AiFallback
+
+	if settings.BaseUrl == "" {
+		return nil, nil
+	}
+
+	for stage, task := range configurationService.AiTasks {
+		if task.Images && !modelCapabilities[settings.ImageModel].Vision {
+			return nil, fmt.Errorf("the fallback model '%s' of the %s is not declared with the '%s' capability", settings.ImageModel, stage, VisionCapability)
+		}
+	}
+
+	var extraOptions []option.RequestOption
+
+	//	The key of the primary provider, read from the environment by the client, is not sent to the fallback one
+	if settings.ApiKey == "" {
+		extraOptions = append(extraOptions, option.WithHeaderDel("authorization"))
+	}
+
+	return &aiFallback{
+		name:       providerName(settings.BaseUrl),
+		client:     newClient(settings.BaseUrl, settings.ApiKey, configurationService.AiRequestTimeout, httpClient, extraOptions...),
+		textModel:  settings.TextModel,
+		imageModel: settings.ImageModel,
+		threshold:  settings.Threshold,
+	}, nil
+}
+
+// providerName names a provider after the host of its base URL.
+func providerName(baseUrl string) string {
+
+	if parsedUrl, err := url.Parse(baseUrl); err == nil && parsedUrl.Host != "" {
+		return parsedUrl.Host
+	}
+
+	return defaultProviderName
+}
+
+func (aiProxy *AiProxy) providerOf(request *aiRequest) string {
+
+	if request.fallback {
+		return aiProxy.fallback.name
+	}
+
+	return aiProxy.provider
+}
+
+func (aiProxy *AiProxy) clientOf(request *aiRequest) *openai.Client {
+
+	if request.fallback {
+		return aiProxy.fallback.client
+	}
+
+	return aiProxy.client
+}
+
+// failOver returns the request as sent to the fallback provider, with its model for the text or the images. The
+// reasoning effort is dropped when the fallback model does not reason.
+func (aiProxy *AiProxy) failOver(request *aiRequest, cause error) (*aiRequest, error) {
+
+	fallbackRequest := *request
+	fallbackRequest.fallback = true
+	fallbackRequest.model = aiProxy.fallback.textModel
+
+	if len(request.images) > 0 {
+		fallbackRequest.model = aiProxy.fallback.imageModel
+	}
+
+	capabilities := aiProxy.modelCapabilities[fallbackRequest.model]
+	fallbackRequest.structuredOutputs = capabilities.StructuredOutputs
+
+	if !capabilities.Reasoning {
+		fallbackRequest.reasoningEffort = ""
+	}
+
+	if aiProxy.cache != nil {
+		fallbackRequest.cacheKey = cacheKey(fallbackRequest.model, request.schema.name, request.call.Vote, request.assistantPrompt, request.images)
+	}
+
+	aiProxy.auditService.Log(entities.Audit{
+		Severity:  entities.Warning,
+		Timestamp: time.Now(),
+		Text: fmt.Sprintf("Request to the model '%s' of %s for the %s of '%s' fails over to the model '%s' of %s: %v",
+			request.model, aiProxy.provider, request.call.Stage, request.call.Folder, fallbackRequest.model, aiProxy.fallback.name, cause)})
+
+	//	The primary provider will not answer, its estimate gives way to the one of the fallback provider
+	request.reservation.settle()
+
+	if err := aiProxy.reserveBudget(&fallbackRequest); err != nil {
+		return nil, err
+	}
+
+	return &fallbackRequest, nil
+}
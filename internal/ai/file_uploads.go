@@ -0,0 +1,175 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"organizer/internal/abstractions/entities"
+	"organizer/internal/imaging"
+
+	openai "github.com/openai/openai-go/v3"
+)
+
+const (
+	//	Time left to delete the uploaded files at the end of the run, even when it was cancelled
+	fileCleanupTimeout = time.Minute
+)
+
+// fileUploads are the images uploaded through the Files API during the run, by hash of their content, so that
+// a page sent to several stages is uploaded once and referenced by its file ID afterwards.
+type fileUploads struct {
+	mutex   sync.Mutex
+	uploads map[string]*fileUpload
+}
+
+// fileUpload is an image uploaded, or being uploaded, its waiters being released once done.
+type fileUpload struct {
+	done   chan struct{}
+	fileID string
+	err    error
+}
+
+func newFileUploads() *fileUploads {
+	return &fileUploads{uploads: make(map[string]*fileUpload)}
+}
+
+// uploadImage returns the file ID of the image, optimizing and uploading it unless it was already during the run.
+// A failed upload is attempted again by the next request sending the image.
+func (aiProxy *AiProxy) uploadImage(ctx context.Context, request *aiRequest, index int, image []byte) (string, error) {
+
+	hash := sha256.Sum256(image)
+	key := hex.EncodeToString(hash[:])
+
+	uploads := aiProxy.fileUploads
+	uploads.mutex.Lock()
+
+	if upload, found := uploads.uploads[key]; found {
+		uploads.mutex.Unlock()
+
+		select {
+		case <-upload.done:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+
+		if upload.err == nil {
+			aiProxy.auditService.Log(entities.Audit{
+				Severity:  entities.Debug,
+				Timestamp: time.Now(),
+				Text:      fmt.Sprintf("Image #%d of the %s of '%s' reuses the file %s", index+1, request.call.Stage, request.call.Folder, upload.fileID)})
+		}
+
+		return upload.fileID, upload.err
+	}
+
+	upload := &fileUpload{done: make(chan struct{})}
+	uploads.uploads[key] = upload
+	uploads.mutex.Unlock()
+
+	defer close(upload.done)
+
+	upload.fileID, upload.err = aiProxy.sendImage(ctx, request, index, image)
+
+	if upload.err != nil {
+		uploads.mutex.Lock()
+		delete(uploads.uploads, key)
+		uploads.mutex.Unlock()
+	}
+
+	return upload.fileID, upload.err
+}
+
+// sendImage optimizes an image and uploads it, returning its file ID.
+func (aiProxy *AiProxy) sendImage(ctx context.Context, request *aiRequest, index int, image []byte) (string, error) {
+
+	optimizedImage, mimeType, err := aiProxy.optimizeImage(request, index, image)
+
+	if err != nil {
+		return "", err
+	}
+
+	extension, purpose := strings.TrimPrefix(mimeType, "image/"), openai.FilePurposeVision
+
+	//	The PDF pages are read as documents rather than images
+	if mimeType == imaging.PdfMimeType {
+		extension, purpose = "pdf", openai.FilePurposeUserData
+	}
+
+	hash := sha256.Sum256(optimizedImage)
+	filename := fmt.Sprintf("page-%s.%s", hex.EncodeToString(hash[:8]), extension)
+
+	var file *openai.FileObject
+
+	err = aiProxy.withRetries(ctx, fmt.Sprintf("Upload of the image #%d of the %s of '%s'", index+1, request.call.Stage, request.call.Folder), func() error {
+
+		file, err = aiProxy.client.Files.New(ctx, openai.FileNewParams{
+			File:    openai.File(bytes.NewReader(optimizedImage), filename, mimeType),
+			Purpose: purpose,
+		})
+
+		return err
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("unable to upload the image #%d: %w", index+1, err)
+	}
+
+	aiProxy.auditService.Log(entities.Audit{
+		Severity:  entities.Debug,
+		Timestamp: time.Now(),
+		Text:      fmt.Sprintf("Image #%d of the %s of '%s' uploaded as the file %s (%d bytes)", index+1, request.call.Stage, request.call.Folder, file.ID, len(optimizedImage))})
+
+	return file.ID, nil
+}
+
+// deleteUploadedFiles deletes the files uploaded during the run.
+func (aiProxy *AiProxy) deleteUploadedFiles() {
+
+	uploads := aiProxy.fileUploads
+	uploads.mutex.Lock()
+	pending := maps.Clone(uploads.uploads)
+	uploads.mutex.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	//	The run context may be cancelled already
+	ctx, cancel := context.WithTimeout(context.Background(), fileCleanupTimeout)
+	defer cancel()
+
+	deleted := 0
+
+	for key, upload := range pending {
+
+		if <-upload.done; upload.err != nil {
+			continue
+		}
+
+		if _, err := aiProxy.client.Files.Delete(ctx, upload.fileID); err != nil {
+			aiProxy.auditService.Log(entities.Audit{
+				Severity:  entities.Warning,
+				Timestamp: time.Now(),
+				Text:      fmt.Sprintf("Unable to delete the uploaded file %s: %v", upload.fileID, err)})
+			continue
+		}
+
+		uploads.mutex.Lock()
+		delete(uploads.uploads, key)
+		uploads.mutex.Unlock()
+
+		deleted++
+	}
+
+	aiProxy.auditService.Log(entities.Audit{
+		Severity:  entities.Information,
+		Timestamp: time.Now(),
+		Text:      fmt.Sprintf("AI files: %d uploaded image(s) deleted", deleted)})
+}
@@ -0,0 +1,240 @@
+package ai
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	governorWindow = time.Minute
+	//	Tokens reserved by a request to a model that has not answered yet in the run
+	defaultTokenEstimate = 1000
+)
+
+// governor limits the requests sent to the models, whichever service sends them: the number of requests in
+// flight, the requests and the tokens per minute. The callers are served in the order they arrived, a caller
+// never overtaking an earlier one, so that a stage sending many requests cannot starve another one.
+type governor struct {
+	maxInFlight       int
+	requestsPerMinute int
+	tokensPerMinute   int64
+	clock             clock
+	mutex             sync.Mutex
+	queue             []*governorTicket
+	inFlight          int
+	spends            []*governorSpend
+	timer             clockTimer
+	waits             int64
+	totalWait         time.Duration
+	longestWait       time.Duration
+}
+
+// governorTicket is a caller waiting for its turn, its spend being recorded once granted.
+type governorTicket struct {
+	tokens  int64
+	granted chan struct{}
+	spend   *governorSpend
+}
+
+// governorSpend is a request sent during the last minute and the tokens it used, or is expected to use.
+type governorSpend struct {
+	at     time.Time
+	tokens int64
+}
+
+// clock tells the time to the governor and wakes it up once the window slid, so that the tests can fake it.
+type clock interface {
+	Now() time.Time
+	AfterFunc(wait time.Duration, wakeUp func()) clockTimer
+}
+
+type clockTimer interface {
+	Stop() bool
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(wait time.Duration, wakeUp func()) clockTimer {
+	return time.AfterFunc(wait, wakeUp)
+}
+
+func newGovernor(maxInFlight int, requestsPerMinute int, tokensPerMinute int) *governor {
+	return &governor{
+		maxInFlight:       maxInFlight,
+		requestsPerMinute: requestsPerMinute,
+		tokensPerMinute:   int64(tokensPerMinute),
+		clock:             systemClock{},
+	}
+}
+
+// acquire waits for the turn of a request expected to use the given tokens. The returned function
+// must be called with the tokens actually used once the request is answered, or failed.
+func (g *governor) acquire(ctx context.Context, tokens int64) (release func(usedTokens int64), waited time.Duration, err error) {
+
+	startedAt := g.clock.Now()
+	ticket := &governorTicket{tokens: tokens, granted: make(chan struct{})}
+
+	g.mutex.Lock()
+	g.queue = append(g.queue, ticket)
+	g.dispatch()
+	g.mutex.Unlock()
+
+	select {
+	case <-ticket.granted:
+	case <-ctx.Done():
+		g.mutex.Lock()
+
+		select {
+		case <-ticket.granted:
+			//	Granted meanwhile, the turn is given back
+			g.inFlight--
+		default:
+			g.remove(ticket)
+		}
+
+		g.dispatch()
+		g.mutex.Unlock()
+
+		return nil, g.clock.Now().Sub(startedAt), ctx.Err()
+	}
+
+	waited = g.clock.Now().Sub(startedAt)
+
+	g.mutex.Lock()
+	g.waits++
+	g.totalWait += waited
+	g.longestWait = max(g.longestWait, waited)
+	g.mutex.Unlock()
+
+	var once sync.Once
+
+	release = func(usedTokens int64) {
+		once.Do(func() {
+			g.mutex.Lock()
+			defer g.mutex.Unlock()
+
+			g.inFlight--
+
+			if usedTokens > 0 {
+				ticket.spend.tokens = usedTokens
+			}
+
+			g.dispatch()
+		})
+	}
+
+	return release, waited, nil
+}
+
+// dispatch grants the turns from the head of the queue while the limits allow it, and schedules a new dispatch when
+// the head has to wait for the window to slide. Called locked.
+func (g *governor) dispatch() {
+
+	for len(g.queue) > 0 {
+
+		now := g.clock.Now()
+		g.slideWindow(now)
+
+		ticket := g.queue[0]
+
+		if g.maxInFlight > 0 && g.inFlight >= g.maxInFlight {
+			return
+		}
+
+		if wait := g.rateWait(now, ticket.tokens); wait > 0 {
+			g.schedule(wait)
+			return
+		}
+
+		g.queue = g.queue[1:]
+		g.inFlight++
+
+		ticket.spend = &governorSpend{at: now, tokens: ticket.tokens}
+		g.spends = append(g.spends, ticket.spend)
+
+		close(ticket.granted)
+	}
+}
+
+// rateWait returns how long the next request has to wait for the requests and tokens of the last minute to allow it.
+func (g *governor) rateWait(now time.Time, tokens int64) time.Duration {
+
+	var wait time.Duration
+
+	if g.requestsPerMinute > 0 && len(g.spends) >= g.requestsPerMinute {
+		wait = g.spends[len(g.spends)-g.requestsPerMinute].at.Add(governorWindow).Sub(now)
+	}
+
+	if g.tokensPerMinute <= 0 || len(g.spends) == 0 {
+		return wait
+	}
+
+	//	A request larger than the limit is let through once the window is empty
+	spentTokens := int64(0)
+
+	for _, spend := range g.spends {
+		spentTokens += spend.tokens
+	}
+
+	for _, spend := range g.spends {
+
+		if spentTokens+tokens <= g.tokensPerMinute {
+			break
+		}
+
+		spentTokens -= spend.tokens
+		wait = max(wait, spend.at.Add(governorWindow).Sub(now))
+	}
+
+	return wait
+}
+
+// slideWindow forgets the requests older than a minute. Called locked.
+func (g *governor) slideWindow(now time.Time) {
+
+	index := 0
+
+	for index < len(g.spends) && now.Sub(g.spends[index].at) >= governorWindow {
+		index++
+	}
+
+	g.spends = g.spends[index:]
+}
+
+func (g *governor) schedule(wait time.Duration) {
+
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+
+	g.timer = g.clock.AfterFunc(wait, func() {
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+
+		g.dispatch()
+	})
+}
+
+func (g *governor) remove(ticket *governorTicket) {
+
+	for index, queued := range g.queue {
+		if queued == ticket {
+			g.queue = append(g.queue[:index], g.queue[index+1:]...)
+			return
+		}
+	}
+}
+
+// statistics returns the number of requests that went through the governor, and how long they waited in total and at most.
+func (g *governor) statistics() (requests int64, totalWait time.Duration, longestWait time.Duration) {
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.waits, g.totalWait, g.longestWait
+}
@@ -0,0 +1,247 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock whose time only moves when the test advances it, waking up the governor when its wait is over.
+type fakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock   *fakeClock
+	at      time.Time
+	wakeUp  func()
+	stopped bool
+}
+
+func (t *fakeTimer) Stop() bool {
+
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+
+	stopped := t.stopped
+	t.stopped = true
+
+	return !stopped
+}
+
+func (c *fakeClock) Now() time.Time {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(wait time.Duration, wakeUp func()) clockTimer {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	timer := &fakeTimer{clock: c, at: c.now.Add(wait), wakeUp: wakeUp}
+	c.timers = append(c.timers, timer)
+
+	return timer
+}
+
+// advance moves the time forward and wakes up the governor when one of its waits is over.
+func (c *fakeClock) advance(duration time.Duration) {
+
+	c.mutex.Lock()
+	c.now = c.now.Add(duration)
+
+	var due []*fakeTimer
+	var pending []*fakeTimer
+
+	for _, timer := range c.timers {
+		switch {
+		case timer.stopped:
+		case !timer.at.After(c.now):
+			timer.stopped = true
+			due = append(due, timer)
+		default:
+			pending = append(pending, timer)
+		}
+	}
+
+	c.timers = pending
+	c.mutex.Unlock()
+
+	for _, timer := range due {
+		timer.wakeUp()
+	}
+}
+
+func newTestGovernor(maxInFlight int, requestsPerMinute int, tokensPerMinute int) (*governor, *fakeClock) {
+
+	clock := &fakeClock{now: time.Date(1999, time.January, 1, 0, 0, 0, 0, time.UTC)}
+
+	g := newGovernor(maxInFlight, requestsPerMinute, tokensPerMinute)
+	g.clock = clock
+
+	return g, clock
+}
+
+type governorTurn struct {
+	release func(usedTokens int64)
+	err     error
+}
+
+// acquireInTurn asks for a turn in the background, once the previous callers are queued.
+func acquireInTurn(t *testing.T, g *governor, ctx context.Context, tokens int64) <-chan governorTurn {
+
+	queued := queueLength(g)
+	turns := make(chan governorTurn, 1)
+
+	go func() {
+		release, _, err := g.acquire(ctx, tokens)
+		turns <- governorTurn{release: release, err: err}
+	}()
+
+	//	Queued, or granted right away
+	deadline := time.Now().Add(time.Second)
+
+	for queueLength(g) == queued && len(turns) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the caller never reached the governor")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	return turns
+}
+
+func queueLength(g *governor) int {
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return len(g.queue)
+}
+
+func expectTurn(t *testing.T, turns <-chan governorTurn, description string) governorTurn {
+
+	t.Helper()
+
+	select {
+	case turn := <-turns:
+		if turn.err != nil {
+			t.Fatalf("expected %s to be granted, got %v", description, turn.err)
+		}
+		return turn
+	case <-time.After(time.Second):
+		t.Fatalf("expected %s to be granted", description)
+		return governorTurn{}
+	}
+}
+
+func expectWaiting(t *testing.T, turns <-chan governorTurn, description string) {
+
+	t.Helper()
+
+	select {
+	case <-turns:
+		t.Fatalf("expected %s to wait", description)
+	default:
+	}
+}
+
+func TestGovernorLimitsTheRequestsInFlight(t *testing.T) {
+
+	g, _ := newTestGovernor(2, 0, 0)
+
+	first := expectTurn(t, acquireInTurn(t, g, context.Background(), 100), "the first request")
+	expectTurn(t, acquireInTurn(t, g, context.Background(), 100), "the second request")
+
+	third := acquireInTurn(t, g, context.Background(), 100)
+	expectWaiting(t, third, "the third request")
+
+	first.release(100)
+
+	expectTurn(t, third, "the third request once the first is answered")
+}
+
+func TestGovernorLimitsTheRequestsPerMinute(t *testing.T) {
+
+	g, clock := newTestGovernor(0, 2, 0)
+
+	expectTurn(t, acquireInTurn(t, g, context.Background(), 100), "the first request").release(100)
+
+	clock.advance(10 * time.Second)
+
+	expectTurn(t, acquireInTurn(t, g, context.Background(), 100), "the second request").release(100)
+
+	third := acquireInTurn(t, g, context.Background(), 100)
+
+	clock.advance(49 * time.Second)
+	expectWaiting(t, third, "the third request within the minute of the first")
+
+	clock.advance(time.Second)
+	expectTurn(t, third, "the third request once the first is a minute old")
+}
+
+func TestGovernorLimitsTheTokensPerMinute(t *testing.T) {
+
+	g, clock := newTestGovernor(0, 0, 1000)
+
+	first := expectTurn(t, acquireInTurn(t, g, context.Background(), 600), "the first request")
+
+	second := acquireInTurn(t, g, context.Background(), 600)
+	expectWaiting(t, second, "the second request exceeding the tokens of the minute")
+
+	//	The first request used fewer tokens than expected, which leaves room for the second
+	first.release(300)
+	expectTurn(t, second, "the second request once the first used fewer tokens").release(600)
+
+	third := acquireInTurn(t, g, context.Background(), 600)
+
+	clock.advance(59 * time.Second)
+	expectWaiting(t, third, "the third request within the minute of the others")
+
+	clock.advance(time.Second)
+	expectTurn(t, third, "the third request once the window slid")
+}
+
+func TestGovernorLetsTheCancelledCallersGo(t *testing.T) {
+
+	g, _ := newTestGovernor(1, 0, 0)
+
+	first := expectTurn(t, acquireInTurn(t, g, context.Background(), 100), "the first request")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	second := acquireInTurn(t, g, ctx, 100)
+	third := acquireInTurn(t, g, context.Background(), 100)
+
+	cancel()
+
+	select {
+	case turn := <-second:
+		if !errors.Is(turn.err, context.Canceled) {
+			t.Fatalf("expected the cancelled request to give up, got %v", turn.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the cancelled request to stop waiting")
+	}
+
+	expectWaiting(t, third, "the third request while the first is in flight")
+
+	first.release(100)
+
+	//	The cancelled caller neither keeps its place in the queue nor a turn
+	expectTurn(t, third, "the third request once the first is answered").release(100)
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if len(g.queue) != 0 || g.inFlight != 0 {
+		t.Errorf("expected the governor to be idle, got %d queued and %d in flight", len(g.queue), g.inFlight)
+	}
+}
@@ -0,0 +1,163 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	//	Structured outputs require an object at the root, so other types are wrapped into this property
+	wrappedSchemaProperty = "items"
+)
+
+// outputSchema is the JSON schema of the Go type a response is decoded into.
+type outputSchema struct {
+	name    string
+	schema  map[string]any
+	wrapped bool
+}
+
+// newOutputSchema derives the strict JSON schema of the value pointed by result.
+func newOutputSchema(result any) (*outputSchema, error) {
+
+	resultType := reflect.TypeOf(result)
+
+	if resultType == nil || resultType.Kind() != reflect.Pointer {
+		return nil, fmt.Errorf("the result must be a pointer, got %v", resultType)
+	}
+
+	resultType = resultType.Elem()
+
+	schema, err := jsonSchemaOf(resultType)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to derive the JSON schema of %v: %v", resultType, err)
+	}
+
+	if resultType.Kind() == reflect.Struct {
+		return &outputSchema{name: schemaNameOf(resultType), schema: schema}, nil
+	}
+
+	return &outputSchema{
+		name: schemaNameOf(resultType),
+		schema: map[string]any{
+			"type":                 "object",
+			"properties":           map[string]any{wrappedSchemaProperty: schema},
+			"required":             []string{wrappedSchemaProperty},
+			"additionalProperties": false,
+		},
+		wrapped: true,
+	}, nil
+}
+
+func jsonSchemaOf(t reflect.Type) (map[string]any, error) {
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonSchemaOf(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := jsonSchemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Struct:
+		return jsonSchemaOfStruct(t)
+	default:
+		return nil, fmt.Errorf("the kind %v is not supported", t.Kind())
+	}
+}
+
+// jsonSchemaOfStruct maps the exported fields using their JSON names. Strict structured
+// outputs require every property to be listed as required.
+func jsonSchemaOfStruct(t reflect.Type) (map[string]any, error) {
+
+	properties := make(map[string]any)
+	required := make([]string, 0, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+
+		field := t.Field(i)
+
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+
+		property, err := jsonSchemaOf(field.Type)
+
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", field.Name, err)
+		}
+
+		properties[name] = property
+		required = append(required, name)
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}, nil
+}
+
+func schemaNameOf(t reflect.Type) string {
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaNameOf(t.Elem())
+	case reflect.Slice, reflect.Array:
+		return schemaNameOf(t.Elem()) + "List"
+	default:
+		if t.Name() != "" {
+			return t.Name()
+		}
+		return t.Kind().String()
+	}
+}
+
+// decode decodes the output text of a response into result, unwrapping it when needed.
+func (o *outputSchema) decode(outputText string, result any) error {
+
+	if !o.wrapped {
+		return json.Unmarshal([]byte(outputText), result)
+	}
+
+	var envelope map[string]json.RawMessage
+
+	if err := json.Unmarshal([]byte(outputText), &envelope); err != nil {
+		return err
+	}
+
+	items, ok := envelope[wrappedSchemaProperty]
+
+	if !ok {
+		return fmt.Errorf("the property '%s' is missing", wrappedSchemaProperty)
+	}
+
+	return json.Unmarshal(items, result)
+}
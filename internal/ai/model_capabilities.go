@@ -0,0 +1,64 @@
+package ai
+
+import (
+	"fmt"
+)
+
+const (
+	VisionCapability            = "vision"
+	StructuredOutputsCapability = "structured"
+	ReasoningCapability         = "reasoning"
+)
+
+// ModelCapabilities describes what a model accepts, so requests only use the features it supports.
+type ModelCapabilities struct {
+	Vision            bool
+	StructuredOutputs bool
+	Reasoning         bool
+}
+
+// knownModelCapabilities are the capabilities of the hosted OpenAI models. Models served by
+// self-hosted OpenAI-compatible servers must be declared in the configuration.
+var knownModelCapabilities = map[string]ModelCapabilities{
+	"gpt-5":        {Vision: true, StructuredOutputs: true, Reasoning: true},
+	"gpt-5-mini":   {Vision: true, StructuredOutputs: true, Reasoning: true},
+	"gpt-5-nano":   {Vision: true, StructuredOutputs: true, Reasoning: true},
+	"gpt-4.1":      {Vision: true, StructuredOutputs: true},
+	"gpt-4.1-mini": {Vision: true, StructuredOutputs: true},
+	"gpt-4.1-nano": {Vision: true, StructuredOutputs: true},
+	"gpt-4o":       {Vision: true, StructuredOutputs: true},
+	"gpt-4o-mini":  {Vision: true, StructuredOutputs: true},
+	"o4-mini":      {Vision: true, StructuredOutputs: true, Reasoning: true},
+}
+
+// newModelCapabilityTable merges the declared capabilities on top of the known ones.
+func newModelCapabilityTable(declaredCapabilities map[string][]string) (map[string]ModelCapabilities, error) {
+
+	table := make(map[string]ModelCapabilities, len(knownModelCapabilities)+len(declaredCapabilities))
+
+	for model, capabilities := range knownModelCapabilities {
+		table[model] = capabilities
+	}
+
+	for model, capabilityNames := range declaredCapabilities {
+
+		var capabilities ModelCapabilities
+
+		for _, capabilityName := range capabilityNames {
+			switch capabilityName {
+			case VisionCapability:
+				capabilities.Vision = true
+			case StructuredOutputsCapability:
+				capabilities.StructuredOutputs = true
+			case ReasoningCapability:
+				capabilities.Reasoning = true
+			default:
+				return nil, fmt.Errorf("unknown capability '%s' declared for the model '%s'", capabilityName, model)
+			}
+		}
+
+		table[model] = capabilities
+	}
+
+	return table, nil
+}
@@ -0,0 +1,197 @@
+package ai
+
+import (
+	"strings"
+)
+
+const (
+	//	Sent along with an answer that could not be decoded, with the error of the decoder
+	reaskPrompt = "Your answer could not be decoded: %s. Answer again with the JSON value only, without code fence nor comment."
+)
+
+var typographicQuotes = strings.NewReplacer("“", `"`, "”", `"`, "„", `"`)
+
+// pythonLiterals are the literals of the models answering in Python rather than in JSON.
+var pythonLiterals = map[string]string{
+	"True":  "true",
+	"False": "false",
+	"None":  "null",
+}
+
+// repairOutput extracts the first JSON value of a free text answer, such as one wrapped in a code fence or followed
+// by a comment, and repairs what is mechanically repairable: typographic quotes, trailing commas, bare words and
+// truncated values. It returns the repaired text and the repairs applied, or false when the answer holds no JSON value.
+func repairOutput(outputText string) (string, []string, bool) {
+
+	var repairs []string
+
+	text := typographicQuotes.Replace(outputText)
+
+	if text != outputText {
+		repairs = append(repairs, "typographic quotes replaced")
+	}
+
+	start := strings.IndexAny(text, "{[")
+
+	if start < 0 {
+		return "", repairs, false
+	}
+
+	var (
+		repaired       []byte
+		closers        []byte
+		inString       bool
+		escaped        bool
+		end            = -1
+		trailingCommas bool
+		bareWords      bool
+		unbalanced     bool
+	)
+
+	//	Removes the comma left before a closing bracket
+	trimTrailingComma := func() {
+		trimmed := strings.TrimRight(string(repaired), " \t\r\n")
+		if strings.HasSuffix(trimmed, ",") {
+			repaired = []byte(trimmed[:len(trimmed)-1])
+			trailingCommas = true
+		}
+	}
+
+	for index := start; index < len(text) && end < 0; index++ {
+
+		character := text[index]
+
+		if inString {
+			repaired = append(repaired, character)
+
+			switch {
+			case escaped:
+				escaped = false
+			case character == '\\':
+				escaped = true
+			case character == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case character == '"':
+			inString = true
+			repaired = append(repaired, character)
+
+		case character == '{':
+			closers = append(closers, '}')
+			repaired = append(repaired, character)
+
+		case character == '[':
+			closers = append(closers, ']')
+			repaired = append(repaired, character)
+
+		case character == '}' || character == ']':
+			if !strings.ContainsRune(string(closers), rune(character)) {
+				//	A closing bracket nothing opened ends the value
+				end = index
+				continue
+			}
+
+			trimTrailingComma()
+
+			for closers[len(closers)-1] != character {
+				repaired = append(repaired, closers[len(closers)-1])
+				closers = closers[:len(closers)-1]
+				unbalanced = true
+			}
+
+			repaired = append(repaired, character)
+			closers = closers[:len(closers)-1]
+
+			if len(closers) == 0 {
+				end = index + 1
+			}
+
+		case isWordCharacter(character) || isSignedWord(text, index):
+			//	A signed word such as '-Infinity' is replaced whole, its sign included
+			signed := !isWordCharacter(character)
+			wordEnd := index
+
+			if signed {
+				wordEnd++
+			}
+
+			for wordEnd < len(text) && (isWordCharacter(text[wordEnd]) || text[wordEnd] >= '0' && text[wordEnd] <= '9') {
+				wordEnd++
+			}
+
+			word := text[index:wordEnd]
+
+			switch literal, found := pythonLiterals[word]; {
+			case word == "true" || word == "false" || word == "null":
+				repaired = append(repaired, word...)
+			case found:
+				repaired = append(repaired, literal...)
+				bareWords = true
+			default:
+				//	Typically 'Unknown' given for a value the model could not read
+				repaired = append(repaired, "null"...)
+				bareWords = true
+			}
+
+			index = wordEnd - 1
+
+		case character == '-' || character >= '0' && character <= '9':
+			//	Numbers are copied whole, their exponent not being taken for a bare word
+			numberEnd := index
+
+			for numberEnd < len(text) && strings.IndexByte("0123456789+-.eE", text[numberEnd]) >= 0 {
+				numberEnd++
+			}
+
+			repaired = append(repaired, text[index:numberEnd]...)
+			index = numberEnd - 1
+
+		default:
+			repaired = append(repaired, character)
+		}
+	}
+
+	if len(closers) > 0 {
+
+		if inString {
+			repaired = append(repaired, '"')
+		}
+
+		trimTrailingComma()
+
+		for index := len(closers) - 1; index >= 0; index-- {
+			repaired = append(repaired, closers[index])
+		}
+
+		repairs = append(repairs, "truncated value closed")
+	} else if start > 0 || strings.TrimSpace(text[end:]) != "" {
+		repairs = append(repairs, "value extracted from the surrounding text")
+	}
+
+	if trailingCommas {
+		repairs = append(repairs, "trailing commas removed")
+	}
+
+	if bareWords {
+		repairs = append(repairs, "bare words replaced")
+	}
+
+	if unbalanced {
+		repairs = append(repairs, "unbalanced brackets closed")
+	}
+
+	return string(repaired), repairs, true
+}
+
+// isSignedWord tells whether the character is the sign of a bare word, such as the one of '-Infinity'.
+func isSignedWord(text string, index int) bool {
+	return (text[index] == '-' || text[index] == '+') && index+1 < len(text) && isWordCharacter(text[index+1])
+}
+
+func isWordCharacter(character byte) bool {
+	return character >= 'a' && character <= 'z' || character >= 'A' && character <= 'Z' || character == '_'
+}
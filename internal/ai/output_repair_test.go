@@ -0,0 +1,123 @@
+package ai
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestRepairOutput(t *testing.T) {
+
+	for _, test := range []struct {
+		name     string
+		output   string
+		repaired string
+		repairs  []string
+	}{
+		{
+			name:     "valid value",
+			output:   `{"title":"Tilt","number":3}`,
+			repaired: `{"title":"Tilt","number":3}`,
+		},
+		{
+			name:     "code fence",
+			output:   "```json\n{\"title\":\"Tilt\"}\n```",
+			repaired: `{"title":"Tilt"}`,
+			repairs:  []string{"value extracted from the surrounding text"},
+		},
+		{
+			name:     "surrounding commentary",
+			output:   `Here are the pages: [{"file":"a.jpg","number":1}] I sorted them by their names.`,
+			repaired: `[{"file":"a.jpg","number":1}]`,
+			repairs:  []string{"value extracted from the surrounding text"},
+		},
+		{
+			name:     "closing bracket of the commentary",
+			output:   `{"title":"Tilt"} (the number is unreadable]`,
+			repaired: `{"title":"Tilt"}`,
+			repairs:  []string{"value extracted from the surrounding text"},
+		},
+		{
+			name:     "Unknown",
+			output:   `{"title":"Tilt","number":Unknown}`,
+			repaired: `{"title":"Tilt","number":null}`,
+			repairs:  []string{"bare words replaced"},
+		},
+		{
+			name:     "True",
+			output:   `{"cover":True,"index":False,"number":None}`,
+			repaired: `{"cover":true,"index":false,"number":null}`,
+			repairs:  []string{"bare words replaced"},
+		},
+		{
+			name:     "typographic quotes",
+			output:   `{“title”:“Tilt”}`,
+			repaired: `{"title":"Tilt"}`,
+			repairs:  []string{"typographic quotes replaced"},
+		},
+		{
+			name:     "trailing commas",
+			output:   `{"months":[1,2,],"year":1999,}`,
+			repaired: `{"months":[1,2],"year":1999}`,
+			repairs:  []string{"trailing commas removed"},
+		},
+		{
+			name:     "truncated string",
+			output:   `{"title":"Til`,
+			repaired: `{"title":"Til"}`,
+			repairs:  []string{"truncated value closed"},
+		},
+		{
+			name:     "truncated list",
+			output:   `[{"file":"a.jpg","number":1},{"file":"b.jpg","number":2},`,
+			repaired: `[{"file":"a.jpg","number":1},{"file":"b.jpg","number":2}]`,
+			repairs:  []string{"truncated value closed", "trailing commas removed"},
+		},
+		{
+			name:     "unbalanced brackets",
+			output:   `{"months":[1,2}`,
+			repaired: `{"months":[1,2]}`,
+			repairs:  []string{"unbalanced brackets closed"},
+		},
+		{
+			name:     "signed bare words",
+			output:   `{"n":-Infinity,"m":+Infinity,"o":-NaN}`,
+			repaired: `{"n":null,"m":null,"o":null}`,
+			repairs:  []string{"bare words replaced"},
+		},
+		{
+			name:     "numbers",
+			output:   `{"confidence":-1.5e-3,"year":1999}`,
+			repaired: `{"confidence":-1.5e-3,"year":1999}`,
+		},
+		{
+			name:     "bare words within strings",
+			output:   `{"title":"Unknown - True Stories"}`,
+			repaired: `{"title":"Unknown - True Stories"}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+
+			repaired, repairs, found := repairOutput(test.output)
+
+			if !found || repaired != test.repaired {
+				t.Errorf("expected '%s', got '%s' (found: %t)", test.repaired, repaired, found)
+			}
+
+			if !json.Valid([]byte(repaired)) {
+				t.Errorf("expected valid JSON, got '%s'", repaired)
+			}
+
+			if !slices.Equal(repairs, test.repairs) {
+				t.Errorf("expected the repairs %v, got %v", test.repairs, repairs)
+			}
+		})
+	}
+}
+
+func TestRepairOutputWithoutValue(t *testing.T) {
+
+	if repaired, _, found := repairOutput("I cannot read the cover."); found {
+		t.Errorf("expected no value to be found, got '%s'", repaired)
+	}
+}
@@ -0,0 +1,192 @@
+package ai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	cacheEntryExtension = ".json"
+)
+
+// ResponseCache stores the AI responses on disk, keyed on the content of the requests.
+type ResponseCache struct {
+	directory string
+	ttl       time.Duration
+	hits      atomic.Int64
+	misses    atomic.Int64
+}
+
+type cacheEntry struct {
+	Model      string    `json:"model"`
+	CreatedAt  time.Time `json:"createdAt"`
+	OutputText string    `json:"outputText"`
+}
+
+// NewResponseCache creates a cache under the given directory. A zero ttl never expires the entries.
+func NewResponseCache(directory string, ttl time.Duration) (*ResponseCache, error) {
+
+	if err := os.MkdirAll(directory, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("unable to create the cache directory %s: %v", directory, err)
+	}
+
+	return &ResponseCache{
+		directory: directory,
+		ttl:       ttl,
+	}, nil
+}
+
+// cacheKey hashes the model, the expected output, the prompt and the SHA-256 of each image. The additional
+// votes of a request have their own entries, so that they are not answered by the first one.
+func cacheKey(model string, schemaName string, vote int, assistantPrompt string, images [][]byte) string {
+
+	hash := sha256.New()
+
+	for _, part := range []string{model, schemaName, assistantPrompt} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+
+	if vote > 0 {
+		hash.Write([]byte(fmt.Sprintf("vote %d", vote)))
+		hash.Write([]byte{0})
+	}
+
+	for _, image := range images {
+		imageHash := sha256.Sum256(image)
+		hash.Write(imageHash[:])
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (c *ResponseCache) path(key string) string {
+	return filepath.Join(c.directory, key[:2], key+cacheEntryExtension)
+}
+
+// Get returns the cached output text of the key, if any and not expired.
+func (c *ResponseCache) Get(key string) (string, bool) {
+
+	content, err := os.ReadFile(c.path(key))
+
+	if err != nil {
+		c.misses.Add(1)
+		return "", false
+	}
+
+	var entry cacheEntry
+
+	if err := json.Unmarshal(content, &entry); err != nil || c.expired(entry) {
+		c.misses.Add(1)
+		return "", false
+	}
+
+	c.hits.Add(1)
+
+	return entry.OutputText, true
+}
+
+func (c *ResponseCache) Put(key string, model string, outputText string) error {
+
+	path := c.path(key)
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create the cache directory %s: %v", filepath.Dir(path), err)
+	}
+
+	content, err := json.Marshal(cacheEntry{Model: model, CreatedAt: time.Now(), OutputText: outputText})
+
+	if err != nil {
+		return fmt.Errorf("unable to encode the cache entry: %v", err)
+	}
+
+	//	Writes then renames so that a concurrent reader never sees a partial entry
+	temporaryFile, err := os.CreateTemp(filepath.Dir(path), "entry-*.tmp")
+
+	if err != nil {
+		return fmt.Errorf("unable to write the cache entry %s: %v", path, err)
+	}
+
+	_, err = temporaryFile.Write(content)
+
+	if closeErr := temporaryFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(temporaryFile.Name())
+		return fmt.Errorf("unable to write the cache entry %s: %v", path, err)
+	}
+
+	return os.Rename(temporaryFile.Name(), path)
+}
+
+func (c *ResponseCache) expired(entry cacheEntry) bool {
+	return c.ttl > 0 && time.Since(entry.CreatedAt) > c.ttl
+}
+
+// Statistics returns the number of hits and misses since the cache was created.
+func (c *ResponseCache) Statistics() (hits int64, misses int64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// Clear removes every entry and returns how many were removed.
+func (c *ResponseCache) Clear() (int, error) {
+	return c.remove(func(cacheEntry) bool { return true })
+}
+
+// Prune removes the expired entries and returns how many were removed.
+func (c *ResponseCache) Prune() (int, error) {
+	return c.remove(c.expired)
+}
+
+func (c *ResponseCache) remove(shouldRemove func(cacheEntry) bool) (int, error) {
+
+	removed := 0
+
+	err := filepath.WalkDir(c.directory, func(path string, entry fs.DirEntry, err error) error {
+
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() || filepath.Ext(path) != cacheEntryExtension {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+
+		if err != nil {
+			return err
+		}
+
+		var cached cacheEntry
+
+		//	Unreadable entries are removed as well since they can never be hits
+		if err := json.Unmarshal(content, &cached); err == nil && !shouldRemove(cached) {
+			return nil
+		}
+
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		removed++
+
+		return nil
+	})
+
+	if err != nil {
+		return removed, fmt.Errorf("unable to clean the cache directory %s: %v", c.directory, err)
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,121 @@
+package ai
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCacheKey(t *testing.T) {
+
+	images := [][]byte{[]byte("cover"), []byte("page 2")}
+	key := cacheKey("gpt-5-mini", "metadata", 0, "Analyze the cover", images)
+
+	if key != cacheKey("gpt-5-mini", "metadata", 0, "Analyze the cover", [][]byte{[]byte("cover"), []byte("page 2")}) {
+		t.Error("expected the same request to have the same key")
+	}
+
+	for name, other := range map[string]string{
+		"model":       cacheKey("gpt-5", "metadata", 0, "Analyze the cover", images),
+		"schema":      cacheKey("gpt-5-mini", "pages", 0, "Analyze the cover", images),
+		"vote":        cacheKey("gpt-5-mini", "metadata", 1, "Analyze the cover", images),
+		"prompt":      cacheKey("gpt-5-mini", "metadata", 0, "Analyze the back cover", images),
+		"image":       cacheKey("gpt-5-mini", "metadata", 0, "Analyze the cover", [][]byte{[]byte("cover"), []byte("page 3")}),
+		"image order": cacheKey("gpt-5-mini", "metadata", 0, "Analyze the cover", [][]byte{[]byte("page 2"), []byte("cover")}),
+		"no image":    cacheKey("gpt-5-mini", "metadata", 0, "Analyze the cover", nil),
+	} {
+		if other == key {
+			t.Errorf("expected another %s to change the key", name)
+		}
+	}
+}
+
+func TestResponseCacheHitAndMiss(t *testing.T) {
+
+	cache, err := NewResponseCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := cacheKey("gpt-5-nano", "pages", 0, "Sort them", nil)
+
+	if _, found := cache.Get(key); found {
+		t.Error("expected a miss before the response is stored")
+	}
+
+	if err := cache.Put(key, "gpt-5-nano", `{"title":"Tilt"}`); err != nil {
+		t.Fatal(err)
+	}
+
+	if outputText, found := cache.Get(key); !found || outputText != `{"title":"Tilt"}` {
+		t.Errorf("expected a hit with the stored response, got '%s', %t", outputText, found)
+	}
+
+	if hits, misses := cache.Statistics(); hits != 1 || misses != 1 {
+		t.Errorf("expected 1 hit and 1 miss, got %d and %d", hits, misses)
+	}
+}
+
+func TestResponseCacheExpiredEntry(t *testing.T) {
+
+	cache, err := NewResponseCache(t.TempDir(), time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := cacheKey("gpt-5-nano", "pages", 0, "Sort them", nil)
+
+	if err := cache.Put(key, "gpt-5-nano", `[]`); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if _, found := cache.Get(key); found {
+		t.Error("expected the expired entry to be a miss")
+	}
+
+	if removed, err := cache.Prune(); err != nil || removed != 1 {
+		t.Errorf("expected the expired entry to be pruned, got %d removed, %v", removed, err)
+	}
+}
+
+func TestResponseCacheCorruptEntry(t *testing.T) {
+
+	cache, err := NewResponseCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := cacheKey("gpt-5-nano", "pages", 0, "Sort them", nil)
+
+	if err := cache.Put(key, "gpt-5-nano", `[]`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(cache.path(key), []byte(`{"model":"gpt-5-na`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, found := cache.Get(key); found {
+		t.Error("expected the corrupt entry to be a miss")
+	}
+
+	//	A corrupt entry can never be a hit, so pruning removes it even though it did not expire
+	if removed, err := cache.Prune(); err != nil || removed != 1 {
+		t.Errorf("expected the corrupt entry to be pruned, got %d removed, %v", removed, err)
+	}
+
+	if _, err := os.Stat(cache.path(key)); !os.IsNotExist(err) {
+		t.Errorf("expected the corrupt entry to be removed, got %v", err)
+	}
+
+	//	The response stored again replaces it
+	if err := cache.Put(key, "gpt-5-nano", `[{"file":"a.jpg","number":1}]`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, found := cache.Get(key); !found {
+		t.Error("expected the entry stored again to be a hit")
+	}
+}
@@ -0,0 +1,172 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"organizer/internal/abstractions"
+	"organizer/internal/abstractions/entities"
+
+	openai "github.com/openai/openai-go/v3"
+)
+
+const (
+	retryBaseDelay = 1 * time.Second
+	retryMaxDelay  = 60 * time.Second
+)
+
+// retryPolicy retries the transient failures with an exponential backoff and full jitter.
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+// classifyError tells whether an error is transient, and how long the server asked to wait
+// before the next attempt when it said so. An attempt that timed out is transient, the
+// cancellation of the caller being handled before.
+func classifyError(err error) (retryable bool, retryAfter time.Duration) {
+
+	if errors.Is(err, context.Canceled) {
+		return false, 0
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true, 0
+	}
+
+	var apiError *openai.Error
+
+	if errors.As(err, &apiError) {
+
+		if apiError.Response != nil {
+			retryAfter = parseRetryAfter(apiError.Response.Header)
+		}
+
+		switch {
+		case apiError.StatusCode == http.StatusTooManyRequests:
+			//	An exhausted quota will not recover by waiting
+			return apiError.Code != "insufficient_quota", retryAfter
+		case apiError.StatusCode == http.StatusRequestTimeout,
+			apiError.StatusCode == http.StatusConflict,
+			apiError.StatusCode >= http.StatusInternalServerError:
+			return true, retryAfter
+		default:
+			return false, 0
+		}
+	}
+
+	var netError net.Error
+
+	if errors.As(err, &netError) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true, 0
+	}
+
+	return false, 0
+}
+
+// parseRetryAfter reads the 'retry-after-ms' and 'Retry-After' headers, the latter being
+// either a number of seconds or an HTTP date.
+func parseRetryAfter(header http.Header) time.Duration {
+
+	if milliseconds, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && milliseconds > 0 {
+		return time.Duration(milliseconds * float64(time.Millisecond))
+	}
+
+	value := header.Get("Retry-After")
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+
+	return 0
+}
+
+// delay returns how long to wait after the given failed attempt. The server's Retry-After
+// takes precedence over the backoff.
+func (p retryPolicy) delay(attempt int, retryAfter time.Duration) time.Duration {
+
+	if retryAfter > 0 {
+		return retryAfter
+	}
+
+	//	Doubled until the maximum rather than shifted, which would overflow after a few dozen attempts
+	backoff := p.baseDelay
+
+	for doubling := 1; doubling < attempt && backoff < p.maxDelay; doubling++ {
+		backoff *= 2
+	}
+
+	backoff = min(backoff, p.maxDelay)
+
+	return time.Duration(rand.Int64N(int64(backoff)) + 1)
+}
+
+// withRetries runs the operation until it succeeds, fails with a fatal error, runs out of attempts
+// or the context of the caller is done. Its error tells how many attempts were made.
+func (aiProxy *AiProxy) withRetries(ctx context.Context, operationName string, operation func() error) error {
+	return aiProxy.withAttempts(ctx, operationName, aiProxy.retryPolicy.maxAttempts, operation)
+}
+
+// withAttempts runs the operation like withRetries, with at most the given number of attempts.
+func (aiProxy *AiProxy) withAttempts(ctx context.Context, operationName string, maxAttempts int, operation func() error) error {
+
+	for attempt := 1; ; attempt++ {
+
+		err := operation()
+
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			aiProxy.auditService.Log(entities.Audit{
+				Severity:  entities.Warning,
+				Timestamp: time.Now(),
+				Text:      fmt.Sprintf("%s abandoned on attempt %d/%d: %v", operationName, attempt, maxAttempts, context.Cause(ctx))})
+			return &abstractions.AttemptsError{Attempts: attempt, Err: fmt.Errorf("abandoned on attempt %d: %w", attempt, ctx.Err())}
+		}
+
+		retryable, retryAfter := classifyError(err)
+
+		if !retryable {
+			aiProxy.auditService.Log(entities.Audit{
+				Severity:  entities.Error,
+				Timestamp: time.Now(),
+				Text:      fmt.Sprintf("%s failed with a fatal error on attempt %d/%d: %v", operationName, attempt, maxAttempts, err)})
+			return &abstractions.AttemptsError{Attempts: attempt, Err: fmt.Errorf("fatal error after %d attempt(s): %w", attempt, err)}
+		}
+
+		if attempt >= maxAttempts {
+			aiProxy.auditService.Log(entities.Audit{
+				Severity:  entities.Error,
+				Timestamp: time.Now(),
+				Text:      fmt.Sprintf("%s failed with a retryable error on the last attempt %d/%d: %v", operationName, attempt, maxAttempts, err)})
+			return &abstractions.AttemptsError{Attempts: attempt, Err: fmt.Errorf("retryable error persisted after %d attempt(s): %w", attempt, err)}
+		}
+
+		delay := aiProxy.retryPolicy.delay(attempt, retryAfter)
+
+		aiProxy.auditService.Log(entities.Audit{
+			Severity:  entities.Warning,
+			Timestamp: time.Now(),
+			Text:      fmt.Sprintf("%s failed with a retryable error on attempt %d/%d, retrying in %v: %v", operationName, attempt, maxAttempts, delay.Round(time.Millisecond), err)})
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return &abstractions.AttemptsError{Attempts: attempt, Err: ctx.Err()}
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package ai
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+
+	policy := retryPolicy{maxAttempts: 100, baseDelay: retryBaseDelay, maxDelay: retryMaxDelay}
+
+	for _, attempt := range []int{1, 2, 10, 35, 64, 100} {
+		if delay := policy.delay(attempt, 0); delay <= 0 || delay > retryMaxDelay {
+			t.Errorf("the delay after the attempt %d is %v, out of (0, %v]", attempt, delay, retryMaxDelay)
+		}
+	}
+
+	if delay := policy.delay(1, 0); delay > retryBaseDelay {
+		t.Errorf("the delay after the first attempt is %v, above the base delay %v", delay, retryBaseDelay)
+	}
+
+	if delay := policy.delay(3, 5*time.Second); delay != 5*time.Second {
+		t.Errorf("the Retry-After of the server is not honored, got %v", delay)
+	}
+}
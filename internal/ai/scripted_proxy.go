@@ -0,0 +1,195 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"organizer/internal/abstractions"
+	"organizer/internal/abstractions/entities"
+	"organizer/internal/abstractions/interfaces"
+)
+
+// ScriptedRequest is a request received by the ScriptedAiProxy.
+type ScriptedRequest struct {
+	Call   entities.AiCall
+	Prompt string
+	Images [][]byte
+}
+
+// ScriptedAnswer produces the response of a scripted rule.
+type ScriptedAnswer func(request ScriptedRequest) (string, error)
+
+type scriptedRule struct {
+	promptFragment string
+	answer         ScriptedAnswer
+}
+
+// ScriptedAiProxy is an in-memory backend of interfaces.AiProxy answering from a script.
+// It allows the whole pipeline to run offline.
+type ScriptedAiProxy struct {
+	mutex    sync.Mutex
+	rules    []scriptedRule
+	requests []ScriptedRequest
+	//	Whether a scripted answer refused a request for the budget
+	exhausted bool
+}
+
+func NewScripted() *ScriptedAiProxy {
+	return &ScriptedAiProxy{}
+}
+
+// On registers an answer for the requests whose prompt contains the given fragment.
+// Rules are evaluated in the order they were registered.
+func (s *ScriptedAiProxy) On(promptFragment string, answer ScriptedAnswer) *ScriptedAiProxy {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.rules = append(s.rules, scriptedRule{promptFragment: promptFragment, answer: answer})
+
+	return s
+}
+
+// OnRespond registers a fixed response for the requests whose prompt contains the given fragment.
+func (s *ScriptedAiProxy) OnRespond(promptFragment string, response string) *ScriptedAiProxy {
+	return s.On(promptFragment, func(ScriptedRequest) (string, error) {
+		return response, nil
+	})
+}
+
+// Requests returns the requests received so far.
+func (s *ScriptedAiProxy) Requests() []ScriptedRequest {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	requests := make([]ScriptedRequest, len(s.requests))
+	copy(requests, s.requests)
+
+	return requests
+}
+
+func (s *ScriptedAiProxy) SendRequest(ctx context.Context, call entities.AiCall, assistantPrompt string, result any) error {
+	return s.SendRequestWithImages(ctx, call, assistantPrompt, nil, result)
+}
+
+func (s *ScriptedAiProxy) SendRequestWithImage(ctx context.Context, call entities.AiCall, assistantPrompt string, reader io.Reader, result any) error {
+	return s.SendRequestWithImages(ctx, call, assistantPrompt, []io.Reader{reader}, result)
+}
+
+func (s *ScriptedAiProxy) SendRequestWithImages(ctx context.Context, call entities.AiCall, assistantPrompt string, readers []io.Reader, result any) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	request := ScriptedRequest{Call: call, Prompt: assistantPrompt}
+
+	for _, reader := range readers {
+		image, err := io.ReadAll(reader)
+
+		if err != nil {
+			return fmt.Errorf("unable to read the image: %v", err)
+		}
+
+		request.Images = append(request.Images, image)
+	}
+
+	response, err := s.answer(request)
+
+	if errors.Is(err, abstractions.ErrBudgetExhausted) {
+		s.mutex.Lock()
+		s.exhausted = true
+		s.mutex.Unlock()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal([]byte(response), result); err != nil {
+		return fmt.Errorf("unable to decode the response '%s': %v", response, err)
+	}
+
+	return nil
+}
+
+// BudgetExhausted tells whether a scripted answer refused a request for the budget.
+func (s *ScriptedAiProxy) BudgetExhausted() bool {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.exhausted
+}
+
+// answer records the request and returns the raw response of the first matching rule.
+func (s *ScriptedAiProxy) answer(request ScriptedRequest) (string, error) {
+
+	s.mutex.Lock()
+	s.requests = append(s.requests, request)
+	rules := s.rules
+	s.mutex.Unlock()
+
+	for _, rule := range rules {
+		if strings.Contains(request.Prompt, rule.promptFragment) {
+			return rule.answer(request)
+		}
+	}
+
+	return "", fmt.Errorf("no scripted answer matches the prompt: %.80q", request.Prompt)
+}
+
+// NewBatch returns a batch answering its requests from the script when it is run.
+func (s *ScriptedAiProxy) NewBatch() (interfaces.AiBatch, error) {
+	return &scriptedBatch{scriptedAiProxy: s}, nil
+}
+
+type scriptedBatchRequest struct {
+	call            entities.AiCall
+	assistantPrompt string
+	images          []io.Reader
+	result          any
+}
+
+type scriptedBatch struct {
+	scriptedAiProxy *ScriptedAiProxy
+	requests        []scriptedBatchRequest
+}
+
+func (b *scriptedBatch) Add(ctx context.Context, call entities.AiCall, assistantPrompt string, readers []io.Reader, result any) error {
+
+	images := make([]io.Reader, 0, len(readers))
+
+	for _, reader := range readers {
+
+		image, err := io.ReadAll(reader)
+
+		if err != nil {
+			return fmt.Errorf("unable to read the image: %v", err)
+		}
+
+		images = append(images, bytes.NewReader(image))
+	}
+
+	b.requests = append(b.requests, scriptedBatchRequest{call: call, assistantPrompt: assistantPrompt, images: images, result: result})
+
+	return nil
+}
+
+func (b *scriptedBatch) Run(ctx context.Context) ([]error, error) {
+
+	errs := make([]error, len(b.requests))
+
+	for index, request := range b.requests {
+		errs[index] = b.scriptedAiProxy.SendRequestWithImages(ctx, request.call, request.assistantPrompt, request.images, request.result)
+	}
+
+	return errs, nil
+}
@@ -1,13 +1,20 @@
 package analyzer
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"organizer/internal/abstractions"
 	"organizer/internal/abstractions/entities"
 	"organizer/internal/abstractions/interfaces"
-	"organizer/internal/ai"
 	"organizer/internal/audit"
+	"organizer/internal/configuration"
+	"organizer/internal/imaging"
+	"organizer/internal/prompts"
+	"organizer/internal/report"
+	"organizer/internal/review"
 	"os"
 	"path/filepath"
 	"slices"
@@ -16,30 +23,52 @@ import (
 )
 
 const (
-	CoverPageAssistantPrompt      = "You are given a JPG file containing an image of a cover scanner of a French publication. Based on typical naming conventions and any context you can infer, return only the title, publication number and publication month and year in the JSON format `{ \"title\": string, \"months\": [number,], \"year\": number, \"number\": number }`. If you cannot determine it, answer exactly `Unknown`. Do not add any extra explanation."
-	TableOfContentAssistantPrompt = "This page should be a Summary page of a french magazine. Give me each section name with the page numbers. Returns the structure in the following Json format: {\"error\": string, \"entries\": [{\"title\": string, \"pageNumbers\": [number]}]. Order the result by the Numbers from the lower number to the highest. Fill out page numbers between 2 sections. Only keep the entries that have the words 'Test(s)', 'Sélection(s)' (case insensitive)"
-	GameTestedAssistantPrompt     = "This page a test of a game. Found the name of the game and the console is on. If it is on the page, return the score given to the game. The result should be return in the following Json format: {\"title\": string, \"console\": string, \"score\": number, \"outOf\": number}."
+	minPublicationYear = 1900
 )
 
 type AnalyzerService struct {
-	aiProxy              *ai.AiProxy
+	coverPagePositions   []int
+	coverAnalysisModels  []string
+	coverMinConfidence   float64
+	coverVotes           int
+	coverVoteCrops       bool
+	coverReviewThreshold float64
+	batchMode            bool
+	aiProxy              interfaces.AiProxy
+	promptService        *prompts.PromptService
 	magazinePagesChannel interfaces.MagazinePagesChannel
 	magazinesChannel     chan entities.Magazine
 	auditService         *audit.AuditService
+	reportService        *report.ReportService
+	reviewService        *review.ReviewService
 	context              context.Context
 	waitGroup            *sync.WaitGroup
 }
 
 func New(
-	aiProxy *ai.AiProxy,
+	configurationService *configuration.ConfigurationService,
+	aiProxy interfaces.AiProxy,
+	promptService *prompts.PromptService,
 	magazinePagesChannel interfaces.MagazinePagesChannel,
 	auditService *audit.AuditService,
+	reportService *report.ReportService,
+	reviewService *review.ReviewService,
 	context context.Context,
 	waitGroup *sync.WaitGroup) *AnalyzerService {
 
 	service := AnalyzerService{
+		coverPagePositions:   configurationService.CoverPagePositions,
+		coverAnalysisModels:  configurationService.AiTasks[entities.CoverAnalysisStage].Models(),
+		coverMinConfidence:   configurationService.CoverMinConfidence,
+		coverVotes:           configurationService.CoverVotes,
+		coverVoteCrops:       configurationService.CoverVoteCrops,
+		coverReviewThreshold: configurationService.CoverReviewThreshold,
+		batchMode:            configurationService.AiBatchMode,
 		aiProxy:              aiProxy,
+		promptService:        promptService,
 		auditService:         auditService,
+		reportService:        reportService,
+		reviewService:        reviewService,
 		magazinePagesChannel: magazinePagesChannel,
 		magazinesChannel:     make(chan entities.Magazine),
 		context:              context,
@@ -69,11 +98,15 @@ func (a *AnalyzerService) Run() {
 
 func (a *AnalyzerService) monitor() error {
 
-	for magazinePages := range a.magazinePagesChannel.Pages() {
-		a.analyzePages(magazinePages)
+	if a.batchMode {
+		a.analyzePagesInBatch()
+	} else {
+		for magazinePages := range a.magazinePagesChannel.Pages() {
+			a.analyzePages(magazinePages)
 
-		//	Future. At the moment, ToC requires a bit more power.
-		//	a.analyzeTableOfContent(magazinePages)
+			//	Future. At the moment, ToC requires a bit more power.
+			//	a.analyzeTableOfContent(magazinePages)
+		}
 	}
 
 	close(a.magazinesChannel)
@@ -83,94 +116,361 @@ func (a *AnalyzerService) monitor() error {
 	return nil
 }
 
+// coverAnalysis is the analysis of the cover of a folder, whose votes are filled once answered.
+type coverAnalysis struct {
+	magazinePages entities.MagazinePages
+	tier          int
+	coverPath     string
+	votes         []coverVote
+}
+
+// coverAnalysisSender sends a vote of the cover analysis, either right away or by adding it to a batch.
+type coverAnalysisSender func(vote *coverVote, call entities.AiCall, assistantPrompt string, readers []io.Reader) error
+
+func (a *AnalyzerService) sendNow(vote *coverVote, call entities.AiCall, assistantPrompt string, readers []io.Reader) error {
+	return a.aiProxy.SendRequestWithImages(a.context, call, assistantPrompt, readers, &vote.metadata)
+}
+
 func (a *AnalyzerService) analyzePages(magazinePages entities.MagazinePages) {
 
-	if magazinePages.Pages == nil || len(magazinePages.Pages) == 0 {
+	analysis := a.sendCoverAnalysis(magazinePages, 0, a.sendNow)
+
+	if analysis == nil {
+		return
+	}
+
+	a.completeCoverAnalysis(analysis)
+}
+
+// analyzePagesInBatch adds the analysis of every folder to a single batch, run once all the folders are scanned.
+func (a *AnalyzerService) analyzePagesInBatch() {
+
+	batchProxy, supported := a.aiProxy.(interfaces.AiBatchProxy)
+
+	if !supported {
 		a.auditService.Log(entities.Audit{
-			Severity:  entities.Information,
+			Severity:  entities.Warning,
 			Timestamp: time.Now(),
-			Text:      fmt.Sprintf("No pages to analyze.")})
+			Text:      fmt.Sprintf("The AI proxy does not support the batch mode, the covers are analyzed in real time.")})
+	}
+
+	var batch interfaces.AiBatch
+
+	if supported {
+		var err error
+
+		batch, err = batchProxy.NewBatch()
+
+		if err != nil {
+			a.auditService.Log(entities.Audit{
+				Severity:  entities.Error,
+				Timestamp: time.Now(),
+				Text:      fmt.Sprintf("Unable to start the batch, the covers are analyzed in real time: %v", err)})
+		}
+	}
+
+	if batch == nil {
+		for magazinePages := range a.magazinePagesChannel.Pages() {
+			a.analyzePages(magazinePages)
+		}
 		return
 	}
 
-	coverFileName := magazinePages.Pages[0].File
+	var analyses []*coverAnalysis
+	var pendingVotes []*coverVote
+
+	addToBatch := func(vote *coverVote, call entities.AiCall, assistantPrompt string, readers []io.Reader) error {
+
+		if err := batch.Add(a.context, call, assistantPrompt, readers, &vote.metadata); err != nil {
+			return err
+		}
+
+		pendingVotes = append(pendingVotes, vote)
+
+		return nil
+	}
+
+	for magazinePages := range a.magazinePagesChannel.Pages() {
+		if analysis := a.sendCoverAnalysis(magazinePages, 0, addToBatch); analysis != nil {
+			analyses = append(analyses, analysis)
+		}
+	}
 
 	a.auditService.Log(entities.Audit{
 		Severity:  entities.Information,
 		Timestamp: time.Now(),
-		Text:      fmt.Sprintf("Analyzing cover file '%s'\n", coverFileName)})
+		Text:      fmt.Sprintf("Running the batch of %d cover analysis request(s) for %d folder(s)", len(pendingVotes), len(analyses))})
 
-	coverPath := filepath.Join(magazinePages.Folder, coverFileName)
+	errs, err := batch.Run(a.context)
+
+	for index, vote := range pendingVotes {
+		if err != nil {
+			vote.err = err
+		} else {
+			vote.err = errs[index]
+		}
+	}
 
-	if _, err := os.Stat(coverPath); err != nil {
+	for _, analysis := range analyses {
+		a.completeCoverAnalysis(analysis)
+	}
+}
+
+// sendCoverAnalysis sends the votes of the analysis of the cover, along with the selected pages. It returns
+// no analysis when the folder cannot be analyzed, the reason being already audited.
+func (a *AnalyzerService) sendCoverAnalysis(magazinePages entities.MagazinePages, tier int, send coverAnalysisSender) *coverAnalysis {
+
+	if magazinePages.Pages == nil || len(magazinePages.Pages) == 0 {
 		a.auditService.Log(entities.Audit{
-			Severity:  entities.Error,
+			Severity:  entities.Information,
 			Timestamp: time.Now(),
-			Text:      fmt.Sprintf("Cover file '%s' does not exist or is not accessible: %v", coverPath, err)})
-		return
+			Text:      fmt.Sprintf("No pages to analyze.")})
+		return nil
 	}
 
-	reader, err := os.Open(coverPath)
+	coverPrompt, err := a.promptService.Render(entities.CoverAnalysisStage, prompts.PromptData{FolderName: filepath.Base(magazinePages.Folder)})
 
 	if err != nil {
+		a.recordFailure(magazinePages.Folder, fmt.Errorf("unable to prepare the analysis: %w", err))
+		return nil
+	}
+
+	coverPages := a.selectCoverPages(magazinePages.Pages)
+
+	analysis := &coverAnalysis{
+		magazinePages: magazinePages,
+		tier:          tier,
+		coverPath:     filepath.Join(magazinePages.Folder, coverPages[0].File),
+		votes:         make([]coverVote, a.coverVotes),
+	}
+
+	//	The pages are read once since every vote sends them
+	pageContents := make([][]byte, 0, len(coverPages))
+
+	for _, coverPage := range coverPages {
+
 		a.auditService.Log(entities.Audit{
-			Severity:  entities.Error,
+			Severity:  entities.Information,
 			Timestamp: time.Now(),
-			Text:      fmt.Sprintf("Cover file '%s' does not exist or is not accessible: %v", coverPath, err)})
-		return
-	}
+			Text:      fmt.Sprintf("Analyzing cover file '%s' (page %d)\n", coverPage.File, coverPage.Number)})
+
+		pagePath := filepath.Join(magazinePages.Folder, coverPage.File)
+
+		content, err := os.ReadFile(pagePath)
 
-	defer func(reader *os.File) {
-		err := reader.Close()
 		if err != nil {
-			a.auditService.Log(entities.Audit{
-				Severity:  entities.Error,
-				Timestamp: time.Now(),
-				Text:      fmt.Sprintf("Unable to close the cover file '%s': %v", coverPath, err)})
+			a.recordFailure(magazinePages.Folder, fmt.Errorf("the cover file '%s' does not exist or is not accessible: %w", pagePath, err))
+			return nil
 		}
-	}(reader)
 
-	response, err := a.aiProxy.SendRequestWithImage(CoverPageAssistantPrompt, reader)
+		pageContents = append(pageContents, content)
+	}
+
+	for index := range analysis.votes {
+
+		readers := make([]io.Reader, 0, len(pageContents))
+
+		for _, content := range a.voteImages(analysis, index, pageContents) {
+			readers = append(readers, bytes.NewReader(content))
+		}
+
+		call := entities.AiCall{Folder: magazinePages.Folder, Stage: entities.CoverAnalysisStage, Tier: tier, Vote: index}
+
+		analysis.votes[index].err = send(&analysis.votes[index], call, coverPrompt, readers)
+	}
+
+	return analysis
+}
+
+// voteImages returns the pages sent by a vote, the additional votes seeing a crop of the cover when enabled.
+func (a *AnalyzerService) voteImages(analysis *coverAnalysis, vote int, pageContents [][]byte) [][]byte {
+
+	if vote == 0 || !a.coverVoteCrops {
+		return pageContents
+	}
+
+	crop := coverVoteCrops[(vote-1)%len(coverVoteCrops)]
+
+	croppedCover, err := imaging.CropImage(pageContents[0], crop)
 
 	if err != nil {
 		a.auditService.Log(entities.Audit{
-			Severity:  entities.Error,
+			Severity:  entities.Warning,
 			Timestamp: time.Now(),
-			Text:      fmt.Sprintf("An error occurred trying to analyze the cover file '%s': %v", coverPath, err)})
-		return
+			Text:      fmt.Sprintf("Unable to crop the cover file '%s' for the vote %d, the whole cover is sent: %v", analysis.coverPath, vote, err)})
+		return pageContents
 	}
 
-	if response == "" || response == "Unknown" {
+	return append([][]byte{croppedCover}, pageContents[1:]...)
+}
+
+// completeCoverAnalysis merges the votes and sends the analyzed magazine to the copier. A rejected answer escalates
+// the analysis to the next model of the cascade, in real time, until one is accepted or the models are exhausted.
+// An accepted answer the votes disagree too much on goes to the review queue instead.
+func (a *AnalyzerService) completeCoverAnalysis(analysis *coverAnalysis) {
+
+	var merge coverVoteMerge
+
+	for {
+		for index, vote := range analysis.votes {
+			if vote.err != nil && len(analysis.votes) > 1 {
+				a.auditService.Log(entities.Audit{
+					Severity:  entities.Warning,
+					Timestamp: time.Now(),
+					Text:      fmt.Sprintf("The vote %d of the analysis of the cover file '%s' failed: %v", index, analysis.coverPath, vote.err)})
+			}
+		}
+
+		var err error
+
+		merge, err = mergeCoverVotes(analysis.votes)
+
+		if err != nil && a.context.Err() != nil {
+			a.auditService.Log(entities.Audit{
+				Severity:  entities.Warning,
+				Timestamp: time.Now(),
+				Text:      fmt.Sprintf("Cover file '%s' not analyzed, the run is cancelled: %v", analysis.coverPath, err)})
+			a.reportService.RecordUnprocessedFolder(analysis.magazinePages.Folder, report.CancelledStatus)
+			return
+		}
+
+		if errors.Is(err, abstractions.ErrBudgetExhausted) {
+			a.auditService.Log(entities.Audit{
+				Severity:  entities.Warning,
+				Timestamp: time.Now(),
+				Text:      fmt.Sprintf("Cover file '%s' not analyzed: %v", analysis.coverPath, err)})
+			a.reportService.RecordUnprocessedFolder(analysis.magazinePages.Folder, report.BudgetExhaustedStatus)
+			return
+		}
+
+		rejection := a.rejectCoverAnalysis(merge.metadata, err)
+
+		if rejection == "" {
+			break
+		}
+
+		if analysis.tier+1 >= len(a.coverAnalysisModels) {
+			failure := fmt.Errorf("unable to analyze the cover file '%s', the answer of the model '%s' (tier %d) is rejected: %s", analysis.coverPath, a.coverAnalysisModels[analysis.tier], analysis.tier, rejection)
+			if err != nil {
+				failure = fmt.Errorf("unable to analyze the cover file '%s' with the model '%s' (tier %d): %w", analysis.coverPath, a.coverAnalysisModels[analysis.tier], analysis.tier, err)
+			}
+			a.recordFailure(analysis.magazinePages.Folder, failure)
+			return
+		}
+
 		a.auditService.Log(entities.Audit{
-			Severity:  entities.Error,
+			Severity:  entities.Warning,
 			Timestamp: time.Now(),
-			Text:      fmt.Sprintf("Unable to retieve the metadata of the cover file '%s'", coverPath)})
-		return
+			Text: fmt.Sprintf("The answer of the model '%s' (tier %d) for the cover file '%s' is rejected (%s), escalating to the model '%s'",
+				a.coverAnalysisModels[analysis.tier], analysis.tier, analysis.coverPath, rejection, a.coverAnalysisModels[analysis.tier+1])})
+
+		analysis = a.sendCoverAnalysis(analysis.magazinePages, analysis.tier+1, a.sendNow)
+
+		if analysis == nil {
+			return
+		}
 	}
 
-	var metadata entities.MagazineMetadata
-	if err := json.Unmarshal([]byte(response), &metadata); err != nil {
+	metadata := merge.metadata
+
+	for _, disagreement := range merge.disagreements {
 		a.auditService.Log(entities.Audit{
-			Severity:  entities.Error,
+			Severity:  entities.Warning,
 			Timestamp: time.Now(),
-			Text:      fmt.Sprintf("Unable to decode the magazine metadata of cover file '%s': %v", coverPath, err)})
+			Text:      fmt.Sprintf("The votes on the cover file '%s' disagree on the %s", analysis.coverPath, disagreement)})
+	}
+
+	if merge.disagreement > a.coverReviewThreshold {
+		reason := fmt.Sprintf("%.0f%% of the votes on the cover dissent", merge.disagreement*100)
+
 		a.auditService.Log(entities.Audit{
-			Severity:  entities.Debug,
+			Severity:  entities.Warning,
 			Timestamp: time.Now(),
-			Text:      fmt.Sprintf("Received: %s\n", response)})
+			Text:      fmt.Sprintf("The folder '%s' is queued for review: %s", analysis.magazinePages.Folder, reason)})
+
+		a.reviewService.Add(review.ReviewItem{
+			Folder:     analysis.magazinePages.Folder,
+			Stage:      entities.CoverAnalysisStage,
+			Reason:     reason,
+			Details:    merge.disagreements,
+			Candidates: merge.candidates(),
+		})
 		return
 	}
 
 	a.auditService.Log(entities.Audit{
 		Severity:  entities.Information,
 		Timestamp: time.Now(),
-		Text:      fmt.Sprintf("Analysis done: found publication title is '%s' and its number is '%d'", metadata.Title, metadata.Number)})
+		Text: fmt.Sprintf("Analysis done: found publication title is '%s' and its number is '%d' (model '%s', tier %d, confidence %.2f)",
+			metadata.Title, metadata.Number, a.coverAnalysisModels[analysis.tier], analysis.tier, metadata.Confidence)})
 
 	a.magazinesChannel <- entities.Magazine{
 		Metadata: metadata,
-		Pages:    magazinePages.Pages,
-		Folder:   magazinePages.Folder,
+		Pages:    analysis.magazinePages.Pages,
+		Folder:   analysis.magazinePages.Folder,
+		Sidecars: analysis.magazinePages.Sidecars,
+	}
+}
+
+// recordFailure records a folder whose cover analysis failed.
+func (a *AnalyzerService) recordFailure(folder string, err error) {
+
+	attempts := abstractions.AttemptsOf(err)
+
+	a.auditService.Log(entities.Audit{
+		Severity:  entities.Error,
+		Timestamp: time.Now(),
+		Text:      fmt.Sprintf("Folder '%s' failed at the %s stage after %d attempt(s): %v", folder, entities.CoverAnalysisStage, attempts, err)})
+
+	a.reportService.RecordFailure(folder, string(entities.CoverAnalysisStage), err, attempts)
+}
+
+// rejectCoverAnalysis returns why the answer of the analysis cannot be accepted, or an empty string.
+func (a *AnalyzerService) rejectCoverAnalysis(metadata entities.MagazineMetadata, err error) string {
+
+	switch {
+	case err != nil:
+		return err.Error()
+	case metadata.Title == "":
+		return "no title was found"
+	case metadata.Year != 0 && (metadata.Year < minPublicationYear || int(metadata.Year) > time.Now().Year()+1):
+		return fmt.Sprintf("the year %d is not plausible", metadata.Year)
+	case metadata.Confidence < a.coverMinConfidence:
+		return fmt.Sprintf("the confidence %.2f is below %.2f", metadata.Confidence, a.coverMinConfidence)
+	}
+
+	for _, month := range metadata.Month {
+		if month < 1 || month > 12 {
+			return fmt.Sprintf("the month %d is not valid", month)
+		}
+	}
+
+	return ""
+}
+
+// selectCoverPages returns the pages sent along with the cover, the cover being always first.
+// Positions start at 1 and negative positions count from the last page.
+func (a *AnalyzerService) selectCoverPages(pages []entities.MagazinePage) []entities.MagazinePage {
+
+	selectedPages := []entities.MagazinePage{pages[0]}
+	selectedIndexes := []int{0}
+
+	for _, position := range a.coverPagePositions {
+
+		index := position - 1
+		if position < 0 {
+			index = len(pages) + position
+		}
+
+		if index < 0 || index >= len(pages) || slices.Contains(selectedIndexes, index) {
+			continue
+		}
+
+		selectedIndexes = append(selectedIndexes, index)
+		selectedPages = append(selectedPages, pages[index])
 	}
+
+	return selectedPages
 }
 
 func (a *AnalyzerService) analyzeTableOfContent(magazinePages entities.MagazinePages) {
@@ -183,6 +483,28 @@ func (a *AnalyzerService) analyzeTableOfContent(magazinePages entities.MagazineP
 		return
 	}
 
+	promptData := prompts.PromptData{FolderName: filepath.Base(magazinePages.Folder)}
+
+	tableOfContentPrompt, err := a.promptService.Render(entities.TableOfContentStage, promptData)
+
+	if err != nil {
+		a.auditService.Log(entities.Audit{
+			Severity:  entities.Error,
+			Timestamp: time.Now(),
+			Text:      fmt.Sprintf("Unable to prepare the analysis of the folder '%s': %v", magazinePages.Folder, err)})
+		return
+	}
+
+	gameTestedPrompt, err := a.promptService.Render(entities.GameTestStage, promptData)
+
+	if err != nil {
+		a.auditService.Log(entities.Audit{
+			Severity:  entities.Error,
+			Timestamp: time.Now(),
+			Text:      fmt.Sprintf("Unable to prepare the analysis of the folder '%s': %v", magazinePages.Folder, err)})
+		return
+	}
+
 	var tableContent entities.TableContent
 
 	for _, page := range magazinePages.Pages[1:] {
@@ -217,33 +539,11 @@ func (a *AnalyzerService) analyzeTableOfContent(magazinePages entities.MagazineP
 			}
 		}(reader)
 
-		response, err := a.aiProxy.SendRequestWithImage(TableOfContentAssistantPrompt, reader)
-
-		if err != nil {
-			a.auditService.Log(entities.Audit{
-				Severity:  entities.Error,
-				Timestamp: time.Now(),
-				Text:      fmt.Sprintf("An error occurred trying to analyze the file '%s': %v", pageFile, err)})
-			return
-		}
-
-		if response == "" {
+		if err := a.aiProxy.SendRequestWithImage(a.context, entities.AiCall{Folder: magazinePages.Folder, Stage: entities.TableOfContentStage}, tableOfContentPrompt, reader, &tableContent); err != nil {
 			a.auditService.Log(entities.Audit{
 				Severity:  entities.Error,
 				Timestamp: time.Now(),
-				Text:      fmt.Sprintf("Unable to retieve the metadata of the file '%s'", pageFile)})
-			return
-		}
-
-		if err := json.Unmarshal([]byte(response), &tableContent); err != nil {
-			a.auditService.Log(entities.Audit{
-				Severity:  entities.Error,
-				Timestamp: time.Now(),
-				Text:      fmt.Sprintf("Unable to decode the table of content of file '%s': %v", pageFile, err)})
-			a.auditService.Log(entities.Audit{
-				Severity:  entities.Debug,
-				Timestamp: time.Now(),
-				Text:      fmt.Sprintf("Received: %s\n", response)})
+				Text:      fmt.Sprintf("Unable to retrieve the table of content of file '%s': %v", pageFile, err)})
 			continue
 		}
 
@@ -293,34 +593,13 @@ func (a *AnalyzerService) analyzeTableOfContent(magazinePages entities.MagazineP
 				}
 			}(reader)
 
-			response, err := a.aiProxy.SendRequestWithImage(GameTestedAssistantPrompt, reader)
-
-			if err != nil {
-				a.auditService.Log(entities.Audit{
-					Severity:  entities.Error,
-					Timestamp: time.Now(),
-					Text:      fmt.Sprintf("An error occurred trying to analyze the file '%s': %v", pageFile, err)})
-				return
-			}
-
-			if response == "" {
-				a.auditService.Log(entities.Audit{
-					Severity:  entities.Error,
-					Timestamp: time.Now(),
-					Text:      fmt.Sprintf("Unable to retrieve the game tested from the file '%s'", pageFile)})
-				return
-			}
-
 			var gameTested entities.Game
-			if err := json.Unmarshal([]byte(response), &gameTested); err != nil {
+
+			if err := a.aiProxy.SendRequestWithImage(a.context, entities.AiCall{Folder: magazinePages.Folder, Stage: entities.GameTestStage}, gameTestedPrompt, reader, &gameTested); err != nil {
 				a.auditService.Log(entities.Audit{
 					Severity:  entities.Error,
 					Timestamp: time.Now(),
-					Text:      fmt.Sprintf("Unable to decode the table of content of file '%s': %v", pageFile, err)})
-				a.auditService.Log(entities.Audit{
-					Severity:  entities.Debug,
-					Timestamp: time.Now(),
-					Text:      fmt.Sprintf("Received: %s\n", response)})
+					Text:      fmt.Sprintf("Unable to retrieve the game tested from the file '%s': %v", pageFile, err)})
 				continue
 			}
 
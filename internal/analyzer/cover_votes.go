@@ -0,0 +1,182 @@
+package analyzer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"organizer/internal/abstractions/entities"
+	"organizer/internal/imaging"
+)
+
+// coverVoteCrops are the crops of the cover seen by the additional votes, in turn. The title and the number
+// are usually printed in the masthead, at the top of the cover.
+var coverVoteCrops = []imaging.Crop{
+	{Left: 0, Top: 0, Right: 1, Bottom: 0.4},
+	{Left: 0.05, Top: 0.05, Right: 0.95, Bottom: 0.95},
+}
+
+// coverVote is one answer of the analysis of a cover.
+type coverVote struct {
+	metadata entities.MagazineMetadata
+	err      error
+}
+
+// coverVoteMerge is the field by field majority of the votes of a cover analysis.
+type coverVoteMerge struct {
+	metadata      entities.MagazineMetadata
+	answers       []entities.MagazineMetadata
+	disagreements []string
+	//	Share of the answers dissenting from the majority, on the most disputed field
+	disagreement float64
+}
+
+// coverCandidate is an answer of the votes as written to the review queue, the months being
+// numbers rather than the base64 encoding of their bytes.
+type coverCandidate struct {
+	Title      string  `json:"title"`
+	Number     uint8   `json:"number"`
+	Months     []int   `json:"months"`
+	Year       uint16  `json:"year"`
+	Confidence float64 `json:"confidence"`
+}
+
+func (m coverVoteMerge) candidates() []coverCandidate {
+
+	candidates := make([]coverCandidate, 0, len(m.answers))
+
+	for _, answer := range m.answers {
+
+		months := make([]int, 0, len(answer.Month))
+
+		for _, month := range answer.Month {
+			months = append(months, int(month))
+		}
+
+		candidates = append(candidates, coverCandidate{
+			Title:      answer.Title,
+			Number:     answer.Number,
+			Months:     months,
+			Year:       answer.Year,
+			Confidence: answer.Confidence,
+		})
+	}
+
+	return candidates
+}
+
+// coverVoteField is a field of the metadata the votes are merged on.
+type coverVoteField struct {
+	name  string
+	key   func(metadata entities.MagazineMetadata) string
+	merge func(merged *entities.MagazineMetadata, winner entities.MagazineMetadata)
+}
+
+var coverVoteFields = []coverVoteField{
+	{
+		name: "title",
+		key: func(metadata entities.MagazineMetadata) string {
+			return strings.ToLower(strings.TrimSpace(metadata.Title))
+		},
+		merge: func(merged *entities.MagazineMetadata, winner entities.MagazineMetadata) {
+			merged.Title = winner.Title
+		},
+	},
+	{
+		name: "number",
+		key: func(metadata entities.MagazineMetadata) string {
+			return fmt.Sprintf("%d", metadata.Number)
+		},
+		merge: func(merged *entities.MagazineMetadata, winner entities.MagazineMetadata) {
+			merged.Number = winner.Number
+		},
+	},
+	{
+		name: "months",
+		key: func(metadata entities.MagazineMetadata) string {
+			return fmt.Sprintf("%v", slices.Sorted(slices.Values(metadata.Month)))
+		},
+		merge: func(merged *entities.MagazineMetadata, winner entities.MagazineMetadata) {
+			merged.Month = winner.Month
+		},
+	},
+	{
+		name: "year",
+		key: func(metadata entities.MagazineMetadata) string {
+			return fmt.Sprintf("%d", metadata.Year)
+		},
+		merge: func(merged *entities.MagazineMetadata, winner entities.MagazineMetadata) {
+			merged.Year = winner.Year
+		},
+	},
+}
+
+// mergeCoverVotes merges the answers field by field by majority, the earliest answer winning the ties. The confidence
+// is the average of the answers. The failed votes are left out, the error of the first one being returned when all failed.
+func mergeCoverVotes(votes []coverVote) (coverVoteMerge, error) {
+
+	var merge coverVoteMerge
+
+	for _, vote := range votes {
+		if vote.err == nil {
+			merge.answers = append(merge.answers, vote.metadata)
+		}
+	}
+
+	if len(merge.answers) == 0 {
+		for _, vote := range votes {
+			if vote.err != nil {
+				return merge, vote.err
+			}
+		}
+		return merge, fmt.Errorf("the cover was not analyzed")
+	}
+
+	for _, field := range coverVoteFields {
+
+		var keys []string
+		counts := make(map[string]int)
+		winners := make(map[string]entities.MagazineMetadata)
+
+		for _, answer := range merge.answers {
+
+			key := field.key(answer)
+
+			if _, found := counts[key]; !found {
+				keys = append(keys, key)
+				winners[key] = answer
+			}
+
+			counts[key]++
+		}
+
+		majorityKey := keys[0]
+
+		for _, key := range keys[1:] {
+			if counts[key] > counts[majorityKey] {
+				majorityKey = key
+			}
+		}
+
+		field.merge(&merge.metadata, winners[majorityKey])
+
+		if len(keys) == 1 {
+			continue
+		}
+
+		candidates := make([]string, 0, len(keys))
+
+		for _, key := range keys {
+			candidates = append(candidates, fmt.Sprintf("'%s' (%d)", key, counts[key]))
+		}
+
+		merge.disagreements = append(merge.disagreements, fmt.Sprintf("%s: %s", field.name, strings.Join(candidates, ", ")))
+		merge.disagreement = max(merge.disagreement, 1-float64(counts[majorityKey])/float64(len(merge.answers)))
+	}
+
+	for _, answer := range merge.answers {
+		merge.metadata.Confidence += answer.Confidence / float64(len(merge.answers))
+	}
+
+	return merge, nil
+}
@@ -3,34 +3,751 @@ package configuration
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"organizer/internal/abstractions/entities"
 )
 
 const (
-	OpenaiApiKeyEnvVarName     = "OPENAI_API_KEY"
-	WorkingDirectoryEnvVarName = "WORKING_DIR"
+	OpenaiApiKeyEnvVarName         = "OPENAI_API_KEY"
+	OpenaiBaseUrlEnvVarName        = "OPENAI_BASE_URL"
+	WorkingDirectoryEnvVarName     = "WORKING_DIR"
+	TextModelEnvVarName            = "AI_TEXT_MODEL"
+	ImageAnalysisModelEnvVarName   = "AI_IMAGE_MODEL"
+	ModelCapabilitiesEnvVarName    = "AI_MODEL_CAPABILITIES"
+	AiMaxAttemptsEnvVarName        = "AI_MAX_ATTEMPTS"
+	AiCacheDirectoryEnvVarName     = "AI_CACHE_DIR"
+	AiCacheTtlEnvVarName           = "AI_CACHE_TTL"
+	AiPricesEnvVarName             = "AI_PRICES"
+	AiCurrencyEnvVarName           = "AI_CURRENCY"
+	AiBudgetTokensEnvVarName       = "AI_BUDGET_TOKENS"
+	AiBudgetCostEnvVarName         = "AI_BUDGET_COST"
+	AiImageMaxEdgeEnvVarName       = "AI_IMAGE_MAX_EDGE"
+	AiImageQualityEnvVarName       = "AI_IMAGE_QUALITY"
+	AiImageUploadEnvVarName        = "AI_IMAGE_UPLOAD"
+	DefaultAiImageMaxEdge          = 2048
+	DefaultAiImageQuality          = 85
+	CoverPagePositionsEnvVarName   = "COVER_PAGE_POSITIONS"
+	PromptDirectoryEnvVarName      = "PROMPT_DIR"
+	PromptLanguageEnvVarName       = "PROMPT_LANGUAGE"
+	ExpectedSeriesEnvVarName       = "EXPECTED_SERIES"
+	ScanDepthEnvVarName            = "SCAN_DEPTH"
+	ScanIssueRuleEnvVarName        = "SCAN_ISSUE_RULE"
+	ScanPagesFolderEnvVarName      = "SCAN_PAGES_FOLDER"
+	ScanIncludeEnvVarName          = "SCAN_INCLUDE"
+	ScanExcludeEnvVarName          = "SCAN_EXCLUDE"
+	LeafIssueRule                  = "leaf"
+	ImagesIssueRule                = "images"
+	DefaultScanDepth               = 1
+	LocalPageOrderingEnvVarName    = "LOCAL_PAGE_ORDERING"
+	OrderingConfidenceEnvVarName   = "LOCAL_PAGE_ORDERING_MIN_CONFIDENCE"
+	DefaultOrderingConfidence      = 0.9
+	DefaultPromptLanguage          = "fr"
+	AiBatchModeEnvVarName          = "AI_BATCH_MODE"
+	AiBatchPollIntervalEnvVarName  = "AI_BATCH_POLL_INTERVAL"
+	DefaultAiBatchPollInterval     = time.Minute
+	AiCassetteModeEnvVarName       = "AI_CASSETTE_MODE"
+	AiCassetteDirectoryEnvVarName  = "AI_CASSETTE_DIR"
+	CassetteRecordMode             = "record"
+	CassetteReplayMode             = "replay"
+	DefaultAiCurrency              = "USD"
+	DefaultTextModel               = "gpt-5-nano"
+	DefaultImageAnalysisModel      = "gpt-5-mini"
+	DefaultAiMaxAttempts           = 4
+	aiTaskModelEnvVarSuffix        = "_MODEL"
+	aiTaskEffortEnvVarSuffix       = "_REASONING_EFFORT"
+	aiTaskMaxTokensEnvVarSuffix    = "_MAX_OUTPUT_TOKENS"
+	aiTaskEscalationEnvVarSuffix   = "_ESCALATION_MODELS"
+	aiTaskVoteModelsEnvVarSuffix   = "_VOTE_MODELS"
+	CoverMinConfidenceEnvVarName   = "AI_COVER_MIN_CONFIDENCE"
+	CoverVotesEnvVarName           = "AI_COVER_VOTES"
+	CoverVoteCropsEnvVarName       = "AI_COVER_VOTE_CROPS"
+	CoverReviewThresholdEnvVarName = "AI_COVER_REVIEW_THRESHOLD"
+	AiMaxInFlightEnvVarName        = "AI_MAX_IN_FLIGHT"
+	AiRequestsPerMinuteEnvVarName  = "AI_REQUESTS_PER_MINUTE"
+	AiTokensPerMinuteEnvVarName    = "AI_TOKENS_PER_MINUTE"
+	AiRequestTimeoutEnvVarName     = "AI_REQUEST_TIMEOUT"
+	DefaultAiRequestTimeout        = 3 * time.Minute
+	AiCallTimeoutEnvVarName        = "AI_CALL_TIMEOUT"
+	DefaultAiCallTimeout           = 10 * time.Minute
+	AiFallbackBaseUrlEnvVarName    = "AI_FALLBACK_BASE_URL"
+	AiFallbackApiKeyEnvVarName     = "AI_FALLBACK_API_KEY"
+	AiFallbackTextModelEnvVarName  = "AI_FALLBACK_TEXT_MODEL"
+	AiFallbackImageModelEnvVarName = "AI_FALLBACK_IMAGE_MODEL"
+	AiFailoverThresholdEnvVarName  = "AI_FAILOVER_THRESHOLD"
+	DefaultAiFailoverThreshold     = 2
+	DefaultAiMaxInFlight           = 4
+	DefaultCoverVotes              = 1
+	DefaultCoverReviewThreshold    = 0.4
+	declarationsSeparator          = ";"
+	modelCapabilityNamesSeparator  = ","
 )
 
 type ConfigurationService struct {
-	OpenAiApiKey     string
-	WorkingDirectory string
+	OpenAiApiKey       string
+	OpenAiBaseUrl      string
+	WorkingDirectory   string
+	FolderDiscovery    DiscoverySettings
+	TextModel          string
+	ImageAnalysisModel string
+	//	Model, reasoning effort and output limit of each stage, the models defaulting to the text or image one
+	AiTasks map[entities.AiStage]AiTask
+	//	Capabilities declared for the models, by model name (e.g. "llava:13b" => ["vision"])
+	ModelCapabilities map[string][]string
+	//	Maximum number of attempts of an AI request, including the first one
+	AiMaxAttempts int
+	//	Timeout of each attempt of an AI request, and of a whole call with its retries, failover and reask, zero
+	//	meaning none
+	AiRequestTimeout time.Duration
+	AiCallTimeout    time.Duration
+	AiCache          CacheSettings
+	AiFallback       FallbackSettings
+	//	Limits of the requests sent to the models by all the services: in flight, and per minute in requests and
+	//	in tokens, zero meaning unlimited
+	AiMaxInFlight       int
+	AiRequestsPerMinute int
+	AiTokensPerMinute   int
+	//	Prices of the models, by model name, overriding the default price table
+	AiPrices   map[string]ModelPrice
+	AiCurrency string
+	//	Budget of the run, in tokens and in currency, zero meaning unlimited
+	AiBudgetTokens int
+	AiBudgetCost   float64
+	//	Longest edge, in pixels, and JPEG quality of the images once optimized for the upload
+	AiImageMaxEdge int
+	AiImageQuality int
+	//	Whether the images are uploaded once through the Files API and referenced by their file ID, rather than
+	//	sent inline with every request
+	AiImageUpload bool
+	//	Confidence below which the cover analysis escalates to the next model, zero meaning any answer is accepted
+	CoverMinConfidence float64
+	//	Number of times each cover is analyzed, whether the additional votes see crops of the cover, and the share
+	//	of dissenting votes above which the folder goes to the review queue
+	CoverVotes           int
+	CoverVoteCrops       bool
+	CoverReviewThreshold float64
+	//	Whether the pages are ordered from their file names without the AI, and the confidence below which the
+	//	ordering falls back to the AI
+	LocalPageOrdering  bool
+	OrderingConfidence float64
+	//	Positions of the pages sent along with the cover for its analysis, negative ones counting from the last page
+	CoverPagePositions []int
+	//	Directory overriding the embedded prompt templates, and language of the prompt set to use
+	PromptDirectory string
+	PromptLanguage  string
+	//	Series the scanned publications are expected to belong to, given as a hint to the prompts
+	ExpectedSeries string
+	//	Whether the covers are analyzed through the Batch API, and how often the batch is polled
+	AiBatchMode         bool
+	AiBatchPollInterval time.Duration
+	//	Whether the HTTP exchanges with the AI are recorded to, or replayed from, the cassette directory
+	AiCassetteMode      string
+	AiCassetteDirectory string
+}
+
+// aiTaskStages are the stages sending AI requests, and whether they send images. Each one is configured by the
+// AI_<STAGE>_MODEL, AI_<STAGE>_REASONING_EFFORT, AI_<STAGE>_MAX_OUTPUT_TOKENS, AI_<STAGE>_ESCALATION_MODELS
+// and AI_<STAGE>_VOTE_MODELS environment variables.
+var aiTaskStages = []struct {
+	stage  entities.AiStage
+	images bool
+}{
+	{stage: entities.PageOrderingStage, images: false},
+	{stage: entities.CoverAnalysisStage, images: true},
+	{stage: entities.TableOfContentStage, images: true},
+	{stage: entities.GameTestStage, images: true},
+}
+
+// reasoningEfforts are the supported reasoning efforts, from the cheapest to the most thorough.
+var reasoningEfforts = []string{"minimal", "low", "medium", "high"}
+
+// AiTask is the configuration of the requests of a stage.
+type AiTask struct {
+	Model string
+	//	Reasoning effort (minimal, low, medium or high), empty meaning the default of the model
+	ReasoningEffort string
+	//	Maximum number of output tokens, reasoning included, zero meaning unlimited
+	MaxOutputTokens int
+	//	Stronger models the requests escalate to, in order, when the answer of the previous one is rejected
+	EscalationModels []string
+	//	Models answering the additional votes of the first tier in turn, the model of the tier answering them when empty
+	VoteModels []string
+	//	Whether the requests of the stage send images
+	Images bool
+}
+
+// Models returns the models of the escalation cascade, by tier.
+func (t AiTask) Models() []string {
+	return append([]string{t.Model}, t.EscalationModels...)
+}
+
+// VoteModel returns the model answering a vote, the first vote being answered by the model of the tier. Once
+// escalated, every vote is answered by the model of the tier, so that the models just rejected do not outvote it.
+func (t AiTask) VoteModel(tier int, vote int) string {
+
+	if tier > 0 || vote == 0 || len(t.VoteModels) == 0 {
+		return t.Models()[tier]
+	}
+
+	return t.VoteModels[(vote-1)%len(t.VoteModels)]
+}
+
+// ModelPrice is the price of a million tokens.
+type ModelPrice struct {
+	Input       float64
+	CachedInput float64
+	Output      float64
+}
+
+// DiscoverySettings are the settings of the discovery of the issue folders below the working directory.
+type DiscoverySettings struct {
+	//	Levels of folders searched below the working directory, the folders of the last level being issues
+	Depth int
+	//	What makes a folder an issue: being a leaf folder holding pages, or the first folder holding pages of its path
+	IssueRule string
+	//	Name of the subfolder holding the pages of an issue, its other subfolders being ignored, if any
+	PagesFolder string
+	//	Glob patterns the names of the issue folders must match, if any
+	Include []string
+	//	Glob patterns of the names of the folders never searched, at any level
+	Exclude []string
+}
+
+// FallbackSettings are the settings of the OpenAI-compatible provider the requests fail over to when the primary one
+// keeps failing. The failover is disabled when no base URL is set.
+type FallbackSettings struct {
+	BaseUrl    string
+	ApiKey     string
+	TextModel  string
+	ImageModel string
+	//	Failed attempts on the primary provider after which a call fails over
+	Threshold int
+}
+
+// CacheSettings are the settings of the AI response cache. The cache is disabled when no directory is set.
+type CacheSettings struct {
+	Directory string
+	//	Age after which an entry expires, zero meaning never
+	Ttl time.Duration
 }
 
 func New() (*ConfigurationService, error) {
 
+	openAiBaseUrl := os.Getenv(OpenaiBaseUrlEnvVarName)
+
+	aiCassetteMode := os.Getenv(AiCassetteModeEnvVarName)
+	if aiCassetteMode != "" && aiCassetteMode != CassetteRecordMode && aiCassetteMode != CassetteReplayMode {
+		return nil, fmt.Errorf("%s environment variable must be '%s' or '%s', got '%s'", AiCassetteModeEnvVarName, CassetteRecordMode, CassetteReplayMode, aiCassetteMode)
+	}
+
+	aiCassetteDirectory := os.Getenv(AiCassetteDirectoryEnvVarName)
+	if aiCassetteMode != "" && aiCassetteDirectory == "" {
+		return nil, fmt.Errorf("%s environment variable is not set", AiCassetteDirectoryEnvVarName)
+	}
+
+	//	The API key is optional when targeting a self-hosted OpenAI-compatible server or replaying cassettes
 	openAiApiKey := os.Getenv(OpenaiApiKeyEnvVarName)
-	if openAiApiKey == "" {
+	if openAiApiKey == "" && openAiBaseUrl == "" && aiCassetteMode != CassetteReplayMode {
 		return nil, fmt.Errorf("%s environment variable is not set", OpenaiApiKeyEnvVarName)
 	}
 
 	workingDir := os.Getenv(WorkingDirectoryEnvVarName)
 	if workingDir == "" {
-		return nil, fmt.Errorf("%s environment variable is not set", OpenaiApiKeyEnvVarName)
+		return nil, fmt.Errorf("%s environment variable is not set", WorkingDirectoryEnvVarName)
+	}
+
+	folderDiscovery, err := newDiscoverySettings()
+	if err != nil {
+		return nil, err
+	}
+
+	modelCapabilities, err := parseModelCapabilities(os.Getenv(ModelCapabilitiesEnvVarName))
+	if err != nil {
+		return nil, fmt.Errorf("%s environment variable is invalid: %v", ModelCapabilitiesEnvVarName, err)
+	}
+
+	aiMaxAttempts, err := getPositiveIntEnvOrDefault(AiMaxAttemptsEnvVarName, DefaultAiMaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	aiRequestTimeout, err := getDurationEnvOrDefault(AiRequestTimeoutEnvVarName, DefaultAiRequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	aiCallTimeout, err := getDurationEnvOrDefault(AiCallTimeoutEnvVarName, DefaultAiCallTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	aiMaxInFlight, err := getPositiveIntEnvOrDefault(AiMaxInFlightEnvVarName, DefaultAiMaxInFlight)
+	if err != nil {
+		return nil, err
+	}
+
+	aiRequestsPerMinute, err := getPositiveIntEnvOrDefault(AiRequestsPerMinuteEnvVarName, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	aiTokensPerMinute, err := getPositiveIntEnvOrDefault(AiTokensPerMinuteEnvVarName, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheSettings, err := NewCacheSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	aiPrices, err := parseModelPrices(os.Getenv(AiPricesEnvVarName))
+	if err != nil {
+		return nil, fmt.Errorf("%s environment variable is invalid: %v", AiPricesEnvVarName, err)
+	}
+
+	aiBudgetTokens, err := getPositiveIntEnvOrDefault(AiBudgetTokensEnvVarName, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	aiBudgetCost, err := getPositiveFloatEnvOrDefault(AiBudgetCostEnvVarName, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	aiImageMaxEdge, err := getPositiveIntEnvOrDefault(AiImageMaxEdgeEnvVarName, DefaultAiImageMaxEdge)
+	if err != nil {
+		return nil, err
+	}
+
+	aiImageQuality, err := getPositiveIntEnvOrDefault(AiImageQualityEnvVarName, DefaultAiImageQuality)
+	if err != nil {
+		return nil, err
+	}
+
+	if aiImageQuality > 100 {
+		return nil, fmt.Errorf("%s environment variable must be between 1 and 100, got '%d'", AiImageQualityEnvVarName, aiImageQuality)
+	}
+
+	aiImageUpload, err := getBoolEnvOrDefault(AiImageUploadEnvVarName, false)
+	if err != nil {
+		return nil, err
+	}
+
+	coverMinConfidence, err := getFractionEnvOrDefault(CoverMinConfidenceEnvVarName, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	coverVotes, err := getPositiveIntEnvOrDefault(CoverVotesEnvVarName, DefaultCoverVotes)
+	if err != nil {
+		return nil, err
+	}
+
+	coverVoteCrops, err := getBoolEnvOrDefault(CoverVoteCropsEnvVarName, false)
+	if err != nil {
+		return nil, err
+	}
+
+	coverReviewThreshold, err := getPositiveFloatEnvOrDefault(CoverReviewThresholdEnvVarName, DefaultCoverReviewThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	if coverReviewThreshold > 1 {
+		return nil, fmt.Errorf("%s environment variable must be between 0 and 1, got '%g'", CoverReviewThresholdEnvVarName, coverReviewThreshold)
+	}
+
+	localPageOrdering, err := getBoolEnvOrDefault(LocalPageOrderingEnvVarName, true)
+	if err != nil {
+		return nil, err
+	}
+
+	orderingConfidence, err := getFractionEnvOrDefault(OrderingConfidenceEnvVarName, DefaultOrderingConfidence)
+	if err != nil {
+		return nil, err
+	}
+
+	coverPagePositions, err := parsePagePositions(os.Getenv(CoverPagePositionsEnvVarName))
+	if err != nil {
+		return nil, fmt.Errorf("%s environment variable is invalid: %v", CoverPagePositionsEnvVarName, err)
+	}
+
+	aiBatchMode, err := getBoolEnvOrDefault(AiBatchModeEnvVarName, false)
+	if err != nil {
+		return nil, err
+	}
+
+	aiBatchPollInterval, err := getDurationEnvOrDefault(AiBatchPollIntervalEnvVarName, DefaultAiBatchPollInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	//	The status of the batch would be polled without a pause
+	if aiBatchPollInterval <= 0 {
+		return nil, fmt.Errorf("%s environment variable must be a duration above zero such as '1m'", AiBatchPollIntervalEnvVarName)
+	}
+
+	textModel := getEnvOrDefault(TextModelEnvVarName, DefaultTextModel)
+	imageAnalysisModel := getEnvOrDefault(ImageAnalysisModelEnvVarName, DefaultImageAnalysisModel)
+
+	aiTasks, err := newAiTasks(textModel, imageAnalysisModel)
+	if err != nil {
+		return nil, err
+	}
+
+	aiFailoverThreshold, err := getPositiveIntEnvOrDefault(AiFailoverThresholdEnvVarName, DefaultAiFailoverThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	aiFallback := FallbackSettings{
+		BaseUrl:    os.Getenv(AiFallbackBaseUrlEnvVarName),
+		ApiKey:     os.Getenv(AiFallbackApiKeyEnvVarName),
+		TextModel:  getEnvOrDefault(AiFallbackTextModelEnvVarName, textModel),
+		ImageModel: getEnvOrDefault(AiFallbackImageModelEnvVarName, imageAnalysisModel),
+		Threshold:  aiFailoverThreshold,
 	}
 
 	configurationService := ConfigurationService{
-		OpenAiApiKey:     openAiApiKey,
-		WorkingDirectory: workingDir,
+		OpenAiApiKey:         openAiApiKey,
+		OpenAiBaseUrl:        openAiBaseUrl,
+		WorkingDirectory:     workingDir,
+		FolderDiscovery:      *folderDiscovery,
+		TextModel:            textModel,
+		ImageAnalysisModel:   imageAnalysisModel,
+		AiTasks:              aiTasks,
+		ModelCapabilities:    modelCapabilities,
+		AiMaxAttempts:        aiMaxAttempts,
+		AiRequestTimeout:     aiRequestTimeout,
+		AiCallTimeout:        aiCallTimeout,
+		AiMaxInFlight:        aiMaxInFlight,
+		AiRequestsPerMinute:  aiRequestsPerMinute,
+		AiTokensPerMinute:    aiTokensPerMinute,
+		AiCache:              *cacheSettings,
+		AiFallback:           aiFallback,
+		AiPrices:             aiPrices,
+		AiCurrency:           getEnvOrDefault(AiCurrencyEnvVarName, DefaultAiCurrency),
+		AiBudgetTokens:       aiBudgetTokens,
+		AiBudgetCost:         aiBudgetCost,
+		AiImageMaxEdge:       aiImageMaxEdge,
+		AiImageQuality:       aiImageQuality,
+		AiImageUpload:        aiImageUpload,
+		CoverMinConfidence:   coverMinConfidence,
+		CoverVotes:           coverVotes,
+		CoverVoteCrops:       coverVoteCrops,
+		CoverReviewThreshold: coverReviewThreshold,
+		CoverPagePositions:   coverPagePositions,
+		LocalPageOrdering:    localPageOrdering,
+		OrderingConfidence:   orderingConfidence,
+		PromptDirectory:      os.Getenv(PromptDirectoryEnvVarName),
+		PromptLanguage:       getEnvOrDefault(PromptLanguageEnvVarName, DefaultPromptLanguage),
+		ExpectedSeries:       os.Getenv(ExpectedSeriesEnvVarName),
+		AiBatchMode:          aiBatchMode,
+		AiBatchPollInterval:  aiBatchPollInterval,
+		AiCassetteMode:       aiCassetteMode,
+		AiCassetteDirectory:  aiCassetteDirectory,
 	}
 
 	return &configurationService, nil
 }
+
+func newDiscoverySettings() (*DiscoverySettings, error) {
+
+	depth, err := getPositiveIntEnvOrDefault(ScanDepthEnvVarName, DefaultScanDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	issueRule := getEnvOrDefault(ScanIssueRuleEnvVarName, LeafIssueRule)
+	if issueRule != LeafIssueRule && issueRule != ImagesIssueRule {
+		return nil, fmt.Errorf("%s environment variable must be '%s' or '%s', got '%s'", ScanIssueRuleEnvVarName, LeafIssueRule, ImagesIssueRule, issueRule)
+	}
+
+	include, err := parsePatterns(os.Getenv(ScanIncludeEnvVarName))
+	if err != nil {
+		return nil, fmt.Errorf("%s environment variable is invalid: %v", ScanIncludeEnvVarName, err)
+	}
+
+	exclude, err := parsePatterns(os.Getenv(ScanExcludeEnvVarName))
+	if err != nil {
+		return nil, fmt.Errorf("%s environment variable is invalid: %v", ScanExcludeEnvVarName, err)
+	}
+
+	return &DiscoverySettings{
+		Depth:       depth,
+		IssueRule:   issueRule,
+		PagesFolder: os.Getenv(ScanPagesFolderEnvVarName),
+		Include:     include,
+		Exclude:     exclude,
+	}, nil
+}
+
+// NewCacheSettings reads the cache settings alone, for the commands that only manage the cache.
+func NewCacheSettings() (*CacheSettings, error) {
+
+	ttl, err := getDurationEnvOrDefault(AiCacheTtlEnvVarName, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CacheSettings{
+		Directory: os.Getenv(AiCacheDirectoryEnvVarName),
+		Ttl:       ttl,
+	}, nil
+}
+
+// newAiTasks reads the configuration of each stage, the text model being the default of the stages without images.
+func newAiTasks(textModel string, imageAnalysisModel string) (map[entities.AiStage]AiTask, error) {
+
+	aiTasks := make(map[entities.AiStage]AiTask, len(aiTaskStages))
+
+	for _, taskStage := range aiTaskStages {
+
+		envVarPrefix := "AI_" + strings.ToUpper(strings.ReplaceAll(string(taskStage.stage), "-", "_"))
+
+		defaultModel := textModel
+		if taskStage.images {
+			defaultModel = imageAnalysisModel
+		}
+
+		reasoningEffort := os.Getenv(envVarPrefix + aiTaskEffortEnvVarSuffix)
+		if reasoningEffort != "" && !slices.Contains(reasoningEfforts, reasoningEffort) {
+			return nil, fmt.Errorf("%s environment variable must be one of %s, got '%s'", envVarPrefix+aiTaskEffortEnvVarSuffix, strings.Join(reasoningEfforts, ", "), reasoningEffort)
+		}
+
+		maxOutputTokens, err := getPositiveIntEnvOrDefault(envVarPrefix+aiTaskMaxTokensEnvVarSuffix, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		aiTasks[taskStage.stage] = AiTask{
+			Model:            getEnvOrDefault(envVarPrefix+aiTaskModelEnvVarSuffix, defaultModel),
+			ReasoningEffort:  reasoningEffort,
+			MaxOutputTokens:  maxOutputTokens,
+			EscalationModels: parseModels(os.Getenv(envVarPrefix + aiTaskEscalationEnvVarSuffix)),
+			VoteModels:       parseModels(os.Getenv(envVarPrefix + aiTaskVoteModelsEnvVarSuffix)),
+			Images:           taskStage.images,
+		}
+	}
+
+	return aiTasks, nil
+}
+
+// parseModels parses a comma-separated list of models.
+func parseModels(value string) []string {
+
+	models := make([]string, 0)
+
+	for _, model := range strings.Split(value, modelCapabilityNamesSeparator) {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+
+	return models
+}
+
+func getEnvOrDefault(name string, defaultValue string) string {
+
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+
+	return value
+}
+
+func getPositiveIntEnvOrDefault(name string, defaultValue int) (int, error) {
+
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("%s environment variable must be a positive integer, got '%s'", name, value)
+	}
+
+	return number, nil
+}
+
+func getBoolEnvOrDefault(name string, defaultValue bool) (bool, error) {
+
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	flag, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s environment variable must be a boolean, got '%s'", name, value)
+	}
+
+	return flag, nil
+}
+
+func getPositiveFloatEnvOrDefault(name string, defaultValue float64) (float64, error) {
+
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("%s environment variable must be a positive number, got '%s'", name, value)
+	}
+
+	return number, nil
+}
+
+// getFractionEnvOrDefault reads a number between 0 and 1, such as a confidence, 0 included.
+func getFractionEnvOrDefault(name string, defaultValue float64) (float64, error) {
+
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 || number > 1 {
+		return 0, fmt.Errorf("%s environment variable must be a number between 0 and 1, got '%s'", name, value)
+	}
+
+	return number, nil
+}
+
+func getDurationEnvOrDefault(name string, defaultValue time.Duration) (time.Duration, error) {
+
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("%s environment variable must be a positive duration such as '72h', got '%s'", name, value)
+	}
+
+	return duration, nil
+}
+
+// parseModelCapabilities parses declarations such as "llava:13b=vision;qwen2.5:7b=structured,reasoning".
+func parseModelCapabilities(value string) (map[string][]string, error) {
+
+	modelCapabilities := make(map[string][]string)
+
+	for _, declaration := range strings.Split(value, declarationsSeparator) {
+
+		declaration = strings.TrimSpace(declaration)
+		if declaration == "" {
+			continue
+		}
+
+		model, capabilityNames, found := strings.Cut(declaration, "=")
+		model = strings.TrimSpace(model)
+		if !found || model == "" {
+			return nil, fmt.Errorf("'%s' is not in the 'model=capability,capability' format", declaration)
+		}
+
+		capabilities := make([]string, 0)
+
+		for _, capability := range strings.Split(capabilityNames, modelCapabilityNamesSeparator) {
+			if capability = strings.TrimSpace(capability); capability != "" {
+				capabilities = append(capabilities, capability)
+			}
+		}
+
+		modelCapabilities[model] = capabilities
+	}
+
+	return modelCapabilities, nil
+}
+
+// parseModelPrices parses declarations such as "gpt-5-mini=0.25/0.025/2.00", the prices of a
+// million input, cached input and output tokens.
+func parseModelPrices(value string) (map[string]ModelPrice, error) {
+
+	modelPrices := make(map[string]ModelPrice)
+
+	for _, declaration := range strings.Split(value, declarationsSeparator) {
+
+		declaration = strings.TrimSpace(declaration)
+		if declaration == "" {
+			continue
+		}
+
+		model, prices, found := strings.Cut(declaration, "=")
+		model = strings.TrimSpace(model)
+		parts := strings.Split(prices, "/")
+		if !found || model == "" || len(parts) != 3 {
+			return nil, fmt.Errorf("'%s' is not in the 'model=input/cachedInput/output' format", declaration)
+		}
+
+		var amounts [3]float64
+
+		for i, part := range parts {
+			amount, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil || amount < 0 {
+				return nil, fmt.Errorf("'%s' is not a valid price in '%s'", part, declaration)
+			}
+			amounts[i] = amount
+		}
+
+		modelPrices[model] = ModelPrice{Input: amounts[0], CachedInput: amounts[1], Output: amounts[2]}
+	}
+
+	return modelPrices, nil
+}
+
+// parsePatterns parses a comma-separated list of glob patterns such as "Issue *,[0-9]*".
+func parsePatterns(value string) ([]string, error) {
+
+	patterns := make([]string, 0)
+
+	for _, pattern := range strings.Split(value, ",") {
+
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("'%s' is not a glob pattern: %v", pattern, err)
+		}
+
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns, nil
+}
+
+// parsePagePositions parses positions such as "1,2,-1", where -1 is the last page.
+func parsePagePositions(value string) ([]int, error) {
+
+	positions := make([]int, 0)
+
+	for _, part := range strings.Split(value, ",") {
+
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		position, err := strconv.Atoi(part)
+		if err != nil || position == 0 {
+			return nil, fmt.Errorf("'%s' is not a page position such as 1, 2 or -1", part)
+		}
+
+		positions = append(positions, position)
+	}
+
+	return positions, nil
+}
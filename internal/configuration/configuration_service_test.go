@@ -0,0 +1,71 @@
+package configuration
+
+import "testing"
+
+func TestConfidencesAcceptZero(t *testing.T) {
+
+	t.Setenv(OpenaiApiKeyEnvVarName, "test")
+	t.Setenv(WorkingDirectoryEnvVarName, ".")
+	t.Setenv(CoverMinConfidenceEnvVarName, "0")
+	t.Setenv(OrderingConfidenceEnvVarName, "0")
+
+	configurationService, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if configurationService.CoverMinConfidence != 0 || configurationService.OrderingConfidence != 0 {
+		t.Errorf("expected both confidences to be 0, got %v and %v", configurationService.CoverMinConfidence, configurationService.OrderingConfidence)
+	}
+
+	for _, value := range []string{"-0.1", "1.5", "high"} {
+		t.Setenv(OrderingConfidenceEnvVarName, value)
+
+		if _, err := New(); err == nil {
+			t.Errorf("expected the confidence '%s' to be refused", value)
+		}
+	}
+}
+
+func TestEscalatedVotesUseTheModelOfTheirTier(t *testing.T) {
+
+	task := AiTask{Model: "gpt-5-nano", EscalationModels: []string{"gpt-5"}, VoteModels: []string{"gpt-5-mini", "gpt-4.1"}}
+
+	for _, expected := range []struct {
+		tier  int
+		vote  int
+		model string
+	}{
+		{tier: 0, vote: 0, model: "gpt-5-nano"},
+		{tier: 0, vote: 1, model: "gpt-5-mini"},
+		{tier: 0, vote: 2, model: "gpt-4.1"},
+		{tier: 0, vote: 3, model: "gpt-5-mini"},
+		{tier: 1, vote: 0, model: "gpt-5"},
+		{tier: 1, vote: 1, model: "gpt-5"},
+		{tier: 1, vote: 2, model: "gpt-5"},
+	} {
+		if model := task.VoteModel(expected.tier, expected.vote); model != expected.model {
+			t.Errorf("expected the vote %d of the tier %d to be answered by '%s', got '%s'", expected.vote, expected.tier, expected.model, model)
+		}
+	}
+}
+
+func TestBatchPollIntervalIsAboveZero(t *testing.T) {
+
+	t.Setenv(OpenaiApiKeyEnvVarName, "test")
+	t.Setenv(WorkingDirectoryEnvVarName, ".")
+
+	for _, value := range []string{"0", "0s", "-1m"} {
+		t.Setenv(AiBatchPollIntervalEnvVarName, value)
+
+		if _, err := New(); err == nil {
+			t.Errorf("expected the poll interval '%s' to be refused", value)
+		}
+	}
+
+	t.Setenv(AiBatchPollIntervalEnvVarName, "30s")
+
+	if _, err := New(); err != nil {
+		t.Errorf("expected the poll interval '30s' to be accepted, got %v", err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"organizer/internal/abstractions/interfaces"
 	"organizer/internal/audit"
 	"organizer/internal/configuration"
+	"organizer/internal/report"
 	"os"
 	"path/filepath"
 	"strings"
@@ -17,12 +18,15 @@ import (
 
 const (
 	Prefix = "test-"
+	//	Stage of the copier failures
+	copyStage = "copy"
 )
 
 type CopierService struct {
 	workingDirectory string
 	magazinesChannel interfaces.MagazinesChannel
 	auditService     *audit.AuditService
+	reportService    *report.ReportService
 	context          context.Context
 	waitGroup        *sync.WaitGroup
 }
@@ -31,12 +35,14 @@ func New(
 	configurationService *configuration.ConfigurationService,
 	magazinesChannel interfaces.MagazinesChannel,
 	auditService *audit.AuditService,
+	reportService *report.ReportService,
 	context context.Context,
 	waitGroup *sync.WaitGroup) *CopierService {
 
 	service := CopierService{
 		workingDirectory: configurationService.WorkingDirectory,
 		auditService:     auditService,
+		reportService:    reportService,
 		magazinesChannel: magazinesChannel,
 		context:          context,
 		waitGroup:        waitGroup,
@@ -67,11 +73,20 @@ func (c *CopierService) monitor() error {
 
 	for magazine := range c.magazinesChannel.Magazines() {
 
+		//	A magazine being copied is finished, the next ones are drained without being copied
+		if c.context.Err() != nil {
+			c.auditService.Log(entities.Audit{Severity: entities.Warning, Timestamp: time.Now(), Text: fmt.Sprintf("Magazine %s %d not transferred, the run is cancelled", magazine.Metadata.Title, magazine.Metadata.Number)})
+			c.reportService.RecordUnprocessedFolder(magazine.Folder, report.CancelledStatus)
+			continue
+		}
+
 		err := c.renameFiles(magazine)
 
+		//	The next magazines are copied all the same, the analyzer waiting for them to be taken
 		if err != nil {
-			c.auditService.Log(entities.Audit{Severity: entities.Information, Timestamp: time.Now(), Text: fmt.Sprintf("Unable to transfer %s %s: %v\n", magazine.Metadata.Title, magazine.Metadata.Number, err)})
-			return err
+			c.auditService.Log(entities.Audit{Severity: entities.Error, Timestamp: time.Now(), Text: fmt.Sprintf("Unable to transfer %s %d: %v", magazine.Metadata.Title, magazine.Metadata.Number, err)})
+			c.reportService.RecordFailure(magazine.Folder, copyStage, err, 1)
+			continue
 		}
 
 		c.auditService.Log(entities.Audit{Severity: entities.Information, Timestamp: time.Now(), Text: fmt.Sprintf("Magazine %s %d transferred\n", magazine.Metadata.Title, magazine.Metadata.Number)})
@@ -118,29 +133,25 @@ func (c *CopierService) renameFiles(magazine entities.Magazine) error {
 		pageFileName := fmt.Sprintf("%03d%s", magazinePage.Number, strings.ToLower(filepath.Ext(magazinePage.File)))
 		dstPath := filepath.Join(newPublicationFolderNumber, pageFileName)
 
-		src, err := os.Open(srcPath)
-		if err != nil {
+		if err := copyFile(srcPath, dstPath); err != nil {
 			fmt.Println(" [FAILED]")
-			err := fmt.Errorf("unable to open source file %s: %v", srcPath, err)
 			return err
 		}
-		defer src.Close()
 
-		dst, err := os.Create(dstPath)
-		if err != nil {
-			fmt.Println(" [FAILED]")
-			err := fmt.Errorf("unable to create destination file %s: %v\n", dstPath, err)
-			return err
-		}
-		defer dst.Close()
+		c.auditService.Log(entities.Audit{Severity: entities.Information, Timestamp: time.Now(), Text: fmt.Sprintf("File %s copied", dstPath)})
+	}
+
+	//	The notes and checksums keep their name
+	for _, sidecar := range magazine.Sidecars {
+		srcPath := filepath.Join(magazine.Folder, sidecar)
+		dstPath := filepath.Join(newPublicationFolderNumber, sidecar)
 
-		if _, err := io.Copy(dst, src); err != nil {
+		if err := copyFile(srcPath, dstPath); err != nil {
 			fmt.Println(" [FAILED]")
-			err := fmt.Errorf("unable to copy the file from %s to %s: %v\n", srcPath, dstPath, err)
 			return err
 		}
 
-		c.auditService.Log(entities.Audit{Severity: entities.Information, Timestamp: time.Now(), Text: fmt.Sprintf("File %s copied", dstPath)})
+		c.auditService.Log(entities.Audit{Severity: entities.Information, Timestamp: time.Now(), Text: fmt.Sprintf("Sidecar file %s copied", dstPath)})
 	}
 
 	fmt.Println(" [OK]")
@@ -148,6 +159,27 @@ func (c *CopierService) renameFiles(magazine entities.Magazine) error {
 	return nil
 }
 
+func copyFile(srcPath string, dstPath string) error {
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("unable to open source file %s: %v", srcPath, err)
+	}
+	defer src.Close()
+
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return fmt.Errorf("unable to create destination file %s: %v\n", dstPath, err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return fmt.Errorf("unable to copy the file from %s to %s: %v\n", srcPath, dstPath, err)
+	}
+
+	return nil
+}
+
 func toNames(nums []uint8) []string {
 	months := []string{
 		"Janvier", "Février", "Mars", "Avril", "Mai", "Juin",
@@ -0,0 +1,83 @@
+package copier
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"organizer/internal/abstractions/entities"
+	"organizer/internal/audit"
+	"organizer/internal/configuration"
+	"organizer/internal/report"
+)
+
+type testMagazines chan entities.Magazine
+
+func (m testMagazines) Magazines() <-chan entities.Magazine {
+	return m
+}
+
+func TestCopyFailureDoesNotStopTheCopier(t *testing.T) {
+
+	//	The audit log is written to the current directory
+	t.Chdir(t.TempDir())
+
+	auditService, err := audit.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scans := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(scans, "page.jpg"), []byte("page"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	//	A file stands where the folder of the first magazine would be created
+	workingDirectory := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(workingDirectory, Prefix+"Broken"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reportService := report.New(&configuration.ConfigurationService{})
+	magazines := make(testMagazines)
+
+	c := &CopierService{
+		workingDirectory: workingDirectory,
+		magazinesChannel: magazines,
+		auditService:     auditService,
+		reportService:    reportService,
+		context:          context.Background(),
+	}
+
+	stopped := make(chan error, 1)
+
+	go func() {
+		stopped <- c.monitor()
+	}()
+
+	for _, title := range []string{"Broken", "Mag"} {
+		select {
+		case magazines <- entities.Magazine{Folder: scans, Pages: []entities.MagazinePage{{File: "page.jpg", Number: 1}}, Metadata: entities.MagazineMetadata{Title: title, Number: 3, Month: []uint8{1}, Year: 1999}}:
+		case <-time.After(time.Second):
+			t.Fatalf("the copier stopped taking the magazines before '%s'", title)
+		}
+	}
+
+	close(magazines)
+
+	if err := <-stopped; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(workingDirectory, Prefix+"Mag", "Numéro 03 | Janvier 1999", "001.jpg")); err != nil {
+		t.Errorf("expected the magazine after the failure to be copied, got %v", err)
+	}
+
+	if !reportService.HasFailures() {
+		t.Error("expected the copy failure to be recorded")
+	}
+}
@@ -0,0 +1,56 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	cropQuality = 95
+)
+
+// Crop is a region of an image, as fractions of its width and height.
+type Crop struct {
+	Left   float64
+	Top    float64
+	Right  float64
+	Bottom float64
+}
+
+// CropImage returns the region of the image, encoded to JPEG.
+func CropImage(content []byte, crop Crop) ([]byte, error) {
+
+	decodedImage, _, err := image.Decode(bytes.NewReader(content))
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode the %s image: %v", DetectMimeType(content), err)
+	}
+
+	bounds := decodedImage.Bounds()
+	width, height := float64(bounds.Dx()), float64(bounds.Dy())
+
+	region := image.Rect(
+		bounds.Min.X+int(crop.Left*width),
+		bounds.Min.Y+int(crop.Top*height),
+		bounds.Min.X+int(crop.Right*width),
+		bounds.Min.Y+int(crop.Bottom*height)).Intersect(bounds)
+
+	if region.Empty() {
+		return nil, fmt.Errorf("the crop %v of the image is empty", crop)
+	}
+
+	destination := image.NewRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+	draw.Copy(destination, image.Point{}, decodedImage, region, draw.Src, nil)
+
+	var buffer bytes.Buffer
+
+	if err := jpeg.Encode(&buffer, destination, &jpeg.Options{Quality: cropQuality}); err != nil {
+		return nil, fmt.Errorf("unable to encode the cropped image: %v", err)
+	}
+
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,99 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"slices"
+
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// uploadableMimeTypes are the image formats accepted by the vision models as is.
+var uploadableMimeTypes = []string{JpegMimeType, PngMimeType, GifMimeType, WebpMimeType}
+
+// ImageOptimizer prepares the scans before they are sent to a model: the formats the models do not
+// accept are converted and the images larger than the maximum edge are downscaled, both being
+// re-encoded to JPEG.
+type ImageOptimizer struct {
+	maxEdge int
+	quality int
+}
+
+func NewImageOptimizer(maxEdge int, quality int) *ImageOptimizer {
+	return &ImageOptimizer{
+		maxEdge: maxEdge,
+		quality: quality,
+	}
+}
+
+// Optimize returns the content to upload and its MIME type.
+func (o *ImageOptimizer) Optimize(content []byte) ([]byte, string, error) {
+
+	mimeType := DetectMimeType(content)
+
+	if mimeType == UnknownMimeType {
+		return nil, "", fmt.Errorf("the content is not a supported image")
+	}
+
+	//	The models read the PDF documents as they are
+	if mimeType == PdfMimeType {
+		return content, mimeType, nil
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to read the %s image: %v", mimeType, err)
+	}
+
+	fitsMaxEdge := max(config.Width, config.Height) <= o.maxEdge
+
+	if fitsMaxEdge && slices.Contains(uploadableMimeTypes, mimeType) {
+		return content, mimeType, nil
+	}
+
+	decodedImage, _, err := image.Decode(bytes.NewReader(content))
+
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to decode the %s image: %v", mimeType, err)
+	}
+
+	if !fitsMaxEdge {
+		decodedImage = o.downscale(decodedImage)
+	}
+
+	var buffer bytes.Buffer
+
+	if err := jpeg.Encode(&buffer, decodedImage, &jpeg.Options{Quality: o.quality}); err != nil {
+		return nil, "", fmt.Errorf("unable to encode the image: %v", err)
+	}
+
+	return buffer.Bytes(), JpegMimeType, nil
+}
+
+// downscale resizes the image so that its longest edge is the maximum edge, keeping its ratio.
+func (o *ImageOptimizer) downscale(source image.Image) image.Image {
+
+	bounds := source.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if width >= height {
+		height = max(height*o.maxEdge/width, 1)
+		width = o.maxEdge
+	} else {
+		width = max(width*o.maxEdge/height, 1)
+		height = o.maxEdge
+	}
+
+	destination := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(destination, destination.Bounds(), source, bounds, draw.Src, nil)
+
+	return destination
+}
@@ -0,0 +1,79 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+)
+
+const (
+	JpegMimeType    = "image/jpeg"
+	PngMimeType     = "image/png"
+	GifMimeType     = "image/gif"
+	WebpMimeType    = "image/webp"
+	BmpMimeType     = "image/bmp"
+	TiffMimeType    = "image/tiff"
+	PdfMimeType     = "application/pdf"
+	UnknownMimeType = "application/octet-stream"
+)
+
+var (
+	tiffLittleEndianSignature = []byte("II*\x00")
+	tiffBigEndianSignature    = []byte("MM\x00*")
+	pdfSignature              = []byte("%PDF-")
+)
+
+const (
+	//	Bytes considered by the sniffing algorithm of net/http
+	sniffLength = 512
+)
+
+// PageMimeTypes are the formats of the scanned pages. The PDF documents are sent to the models as files rather than
+// images.
+var PageMimeTypes = []string{JpegMimeType, PngMimeType, TiffMimeType, WebpMimeType, PdfMimeType}
+
+// DetectMimeType sniffs the MIME type of a content from its first bytes, regardless of the file name.
+func DetectMimeType(content []byte) string {
+
+	//	TIFF is the usual output of scanners but is not part of the sniffing algorithm of net/http
+	if bytes.HasPrefix(content, tiffLittleEndianSignature) || bytes.HasPrefix(content, tiffBigEndianSignature) {
+		return TiffMimeType
+	}
+
+	if bytes.HasPrefix(content, pdfSignature) {
+		return PdfMimeType
+	}
+
+	mimeType := http.DetectContentType(content)
+
+	switch mimeType {
+	case JpegMimeType, PngMimeType, GifMimeType, WebpMimeType, BmpMimeType:
+		return mimeType
+	default:
+		return UnknownMimeType
+	}
+}
+
+// DetectFileMimeType sniffs the MIME type of a file from its first bytes.
+func DetectFileMimeType(path string) (string, error) {
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	header := make([]byte, sniffLength)
+
+	length, err := io.ReadFull(file, header)
+
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	return DetectMimeType(header[:length]), nil
+}
@@ -0,0 +1,123 @@
+package prompts
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+
+	"organizer/internal/abstractions/entities"
+	"organizer/internal/configuration"
+)
+
+const (
+	templateExtension = ".tmpl"
+)
+
+//go:embed templates
+var embeddedTemplates embed.FS
+
+// promptStages are the stages having a prompt, each one being a '<stage>.tmpl' template.
+var promptStages = []entities.AiStage{
+	entities.PageOrderingStage,
+	entities.CoverAnalysisStage,
+	entities.TableOfContentStage,
+	entities.GameTestStage,
+}
+
+// PromptData holds the variables available to the prompt templates.
+type PromptData struct {
+	Language       string
+	ExpectedSeries string
+	FolderName     string
+	Files          []string
+}
+
+// PromptService renders the prompts of the configured language. The templates are read from
+// '<prompt directory>/<language>/<stage>.tmpl', falling back to the embedded defaults.
+type PromptService struct {
+	language       string
+	expectedSeries string
+	templates      map[entities.AiStage]*template.Template
+}
+
+func New(configurationService *configuration.ConfigurationService) (*PromptService, error) {
+
+	var directory fs.FS
+
+	if configurationService.PromptDirectory != "" {
+		directory = os.DirFS(configurationService.PromptDirectory)
+	}
+
+	templates := make(map[entities.AiStage]*template.Template, len(promptStages))
+
+	for _, stage := range promptStages {
+
+		promptTemplate, err := loadTemplate(directory, configurationService.PromptLanguage, stage)
+
+		if err != nil {
+			return nil, err
+		}
+
+		templates[stage] = promptTemplate
+	}
+
+	return &PromptService{
+		language:       configurationService.PromptLanguage,
+		expectedSeries: configurationService.ExpectedSeries,
+		templates:      templates,
+	}, nil
+}
+
+func loadTemplate(directory fs.FS, language string, stage entities.AiStage) (*template.Template, error) {
+
+	name := path.Join(language, string(stage)+templateExtension)
+
+	var source []byte
+	err := fs.ErrNotExist
+
+	if directory != nil {
+		source, err = fs.ReadFile(directory, name)
+	}
+
+	if errors.Is(err, fs.ErrNotExist) {
+		source, err = embeddedTemplates.ReadFile(path.Join("templates", name))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the '%s' prompt of the language '%s': %v", stage, language, err)
+	}
+
+	promptTemplate, err := template.New(name).Option("missingkey=error").Parse(string(source))
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the '%s' prompt of the language '%s': %v", stage, language, err)
+	}
+
+	return promptTemplate, nil
+}
+
+// Render renders the prompt of the stage. The language and the expected series are set from the configuration.
+func (p *PromptService) Render(stage entities.AiStage, data PromptData) (string, error) {
+
+	promptTemplate, found := p.templates[stage]
+
+	if !found {
+		return "", fmt.Errorf("no prompt is defined for the stage '%s'", stage)
+	}
+
+	data.Language = p.language
+	data.ExpectedSeries = p.expectedSeries
+
+	var prompt strings.Builder
+
+	if err := promptTemplate.Execute(&prompt, data); err != nil {
+		return "", fmt.Errorf("unable to render the '%s' prompt: %v", stage, err)
+	}
+
+	return strings.TrimSpace(prompt.String()), nil
+}
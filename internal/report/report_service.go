@@ -0,0 +1,415 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"organizer/internal/abstractions/entities"
+	"organizer/internal/configuration"
+)
+
+// defaultModelPrices are the list prices of a million tokens of the hosted OpenAI models, in USD.
+var defaultModelPrices = map[string]configuration.ModelPrice{
+	"gpt-5":        {Input: 1.25, CachedInput: 0.125, Output: 10.00},
+	"gpt-5-mini":   {Input: 0.25, CachedInput: 0.025, Output: 2.00},
+	"gpt-5-nano":   {Input: 0.05, CachedInput: 0.005, Output: 0.40},
+	"gpt-4.1":      {Input: 2.00, CachedInput: 0.50, Output: 8.00},
+	"gpt-4.1-mini": {Input: 0.40, CachedInput: 0.10, Output: 1.60},
+	"gpt-4.1-nano": {Input: 0.10, CachedInput: 0.025, Output: 0.40},
+	"gpt-4o":       {Input: 2.50, CachedInput: 1.25, Output: 10.00},
+	"gpt-4o-mini":  {Input: 0.15, CachedInput: 0.075, Output: 0.60},
+	"o4-mini":      {Input: 1.10, CachedInput: 0.275, Output: 4.40},
+}
+
+const (
+	//	Requests sent through the Batch API cost half the price
+	batchPriceFactor = 0.5
+)
+
+const (
+	CompletedStatus             = "completed"
+	CompletedWithFailuresStatus = "completed with failures"
+	BudgetExhaustedStatus       = "budget exhausted"
+	CancelledStatus             = "cancelled"
+)
+
+// ReportService accounts for what the run consumed and reports it at the end of the run.
+type ReportService struct {
+	mutex                 sync.Mutex
+	prices                map[string]configuration.ModelPrice
+	currency              string
+	startedAt             time.Time
+	usages                []entities.AiUsage
+	budgetExhaustedReason string
+	cancellationReason    string
+	unprocessedFolders    []UnprocessedFolder
+	failedFolders         []FailedFolder
+}
+
+// UnprocessedFolder is a folder the run gave up on.
+type UnprocessedFolder struct {
+	Folder string `json:"folder"`
+	Reason string `json:"reason"`
+}
+
+// FailedFolder is a folder whose processing failed, the run going on with the other folders.
+type FailedFolder struct {
+	Folder   string `json:"folder"`
+	Stage    string `json:"stage"`
+	Error    string `json:"error"`
+	Attempts int    `json:"attempts"`
+}
+
+// UsageTotals aggregates the usage of several AI requests.
+type UsageTotals struct {
+	Requests          int     `json:"requests"`
+	CacheHits         int     `json:"cacheHits"`
+	Repairs           int     `json:"repairs"`
+	Reasks            int     `json:"reasks"`
+	InputTokens       int64   `json:"inputTokens"`
+	CachedInputTokens int64   `json:"cachedInputTokens"`
+	OutputTokens      int64   `json:"outputTokens"`
+	ReasoningTokens   int64   `json:"reasoningTokens"`
+	Cost              float64 `json:"cost"`
+}
+
+// Report is the machine-readable report of a run. Its provenance lists the providers which answered each stage of
+// each folder.
+type Report struct {
+	StartedAt             time.Time                      `json:"startedAt"`
+	FinishedAt            time.Time                      `json:"finishedAt"`
+	Status                string                         `json:"status"`
+	BudgetExhaustedReason string                         `json:"budgetExhaustedReason,omitempty"`
+	CancellationReason    string                         `json:"cancellationReason,omitempty"`
+	UnprocessedFolders    []UnprocessedFolder            `json:"unprocessedFolders"`
+	FailedFolders         []FailedFolder                 `json:"failedFolders"`
+	Currency              string                         `json:"currency"`
+	Totals                UsageTotals                    `json:"totals"`
+	ByStage               map[string]UsageTotals         `json:"byStage"`
+	ByFolder              map[string]UsageTotals         `json:"byFolder"`
+	ByModel               map[string]UsageTotals         `json:"byModel"`
+	ByProvider            map[string]UsageTotals         `json:"byProvider"`
+	Provenance            map[string]map[string][]string `json:"provenance"`
+	Requests              []entities.AiUsage             `json:"requests"`
+}
+
+func New(configurationService *configuration.ConfigurationService) *ReportService {
+
+	prices := maps.Clone(defaultModelPrices)
+	maps.Copy(prices, configurationService.AiPrices)
+
+	return &ReportService{
+		prices:    prices,
+		currency:  configurationService.AiCurrency,
+		startedAt: time.Now(),
+	}
+}
+
+// RecordUsage prices the usage of an AI request and adds it to the report.
+func (r *ReportService) RecordUsage(usage entities.AiUsage) entities.AiUsage {
+
+	usage.Cost = r.cost(usage)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.usages = append(r.usages, usage)
+
+	return usage
+}
+
+// Price returns the cost of the usage, such as an estimate of the usage of a request.
+func (r *ReportService) Price(usage entities.AiUsage) float64 {
+	return r.cost(usage)
+}
+
+// cost prices the tokens; the cached input tokens are part of the input tokens, at a discount.
+func (r *ReportService) cost(usage entities.AiUsage) float64 {
+
+	price, found := r.prices[usage.Model]
+
+	if !found {
+		return 0
+	}
+
+	uncachedInputTokens := usage.InputTokens - usage.CachedInputTokens
+
+	cost := (float64(uncachedInputTokens)*price.Input +
+		float64(usage.CachedInputTokens)*price.CachedInput +
+		float64(usage.OutputTokens)*price.Output) / 1_000_000
+
+	if usage.Batch {
+		cost *= batchPriceFactor
+	}
+
+	return cost
+}
+
+// Totals returns the usage of the whole run so far.
+func (r *ReportService) Totals() UsageTotals {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var totals UsageTotals
+
+	for _, usage := range r.usages {
+		totals.add(usage)
+	}
+
+	return totals
+}
+
+// AverageUsage returns the average usage of the requests answered by the model, cache hits excluded.
+func (r *ReportService) AverageUsage(model string) UsageTotals {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var totals UsageTotals
+
+	for _, usage := range r.usages {
+		if usage.Model == model && !usage.CacheHit {
+			totals.add(usage)
+		}
+	}
+
+	if totals.Requests == 0 {
+		return totals
+	}
+
+	requests := int64(totals.Requests)
+
+	return UsageTotals{
+		Requests:          1,
+		InputTokens:       totals.InputTokens / requests,
+		CachedInputTokens: totals.CachedInputTokens / requests,
+		OutputTokens:      totals.OutputTokens / requests,
+		ReasoningTokens:   totals.ReasoningTokens / requests,
+		Cost:              totals.Cost / float64(requests),
+	}
+}
+
+// RecordBudgetExhausted marks the run as stopped by its budget.
+func (r *ReportService) RecordBudgetExhausted(reason string) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.budgetExhaustedReason = reason
+}
+
+// RecordCancellation marks the run as cancelled before its end.
+func (r *ReportService) RecordCancellation(reason string) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.cancellationReason = reason
+}
+
+// RecordUnprocessedFolder adds a folder the run gave up on.
+func (r *ReportService) RecordUnprocessedFolder(folder string, reason string) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.unprocessedFolders = append(r.unprocessedFolders, UnprocessedFolder{Folder: folder, Reason: reason})
+}
+
+// RecordFailure adds a folder whose processing failed at the given stage, after the given number of attempts.
+func (r *ReportService) RecordFailure(folder string, stage string, err error, attempts int) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.failedFolders = append(r.failedFolders, FailedFolder{Folder: folder, Stage: stage, Error: err.Error(), Attempts: attempts})
+}
+
+// HasFailures tells whether the processing of a folder failed during the run.
+func (r *ReportService) HasFailures() bool {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return len(r.failedFolders) > 0
+}
+
+func (t *UsageTotals) add(usage entities.AiUsage) {
+
+	t.Requests++
+
+	if usage.CacheHit {
+		t.CacheHits++
+	}
+
+	if len(usage.Repairs) > 0 {
+		t.Repairs++
+	}
+
+	if usage.Reask {
+		t.Reasks++
+	}
+
+	t.InputTokens += usage.InputTokens
+	t.CachedInputTokens += usage.CachedInputTokens
+	t.OutputTokens += usage.OutputTokens
+	t.ReasoningTokens += usage.ReasoningTokens
+	t.Cost += usage.Cost
+}
+
+func addTo(totalsByKey map[string]UsageTotals, key string, usage entities.AiUsage) {
+	totals := totalsByKey[key]
+	totals.add(usage)
+	totalsByKey[key] = totals
+}
+
+func (r *ReportService) build() Report {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	report := Report{
+		StartedAt:             r.startedAt,
+		FinishedAt:            time.Now(),
+		Status:                CompletedStatus,
+		BudgetExhaustedReason: r.budgetExhaustedReason,
+		CancellationReason:    r.cancellationReason,
+		UnprocessedFolders:    slices.Clone(r.unprocessedFolders),
+		FailedFolders:         slices.Clone(r.failedFolders),
+		Currency:              r.currency,
+		ByStage:               make(map[string]UsageTotals),
+		ByFolder:              make(map[string]UsageTotals),
+		ByModel:               make(map[string]UsageTotals),
+		ByProvider:            make(map[string]UsageTotals),
+		Provenance:            make(map[string]map[string][]string),
+		Requests:              slices.Clone(r.usages),
+	}
+
+	if len(r.failedFolders) > 0 {
+		report.Status = CompletedWithFailuresStatus
+	}
+
+	if r.budgetExhaustedReason != "" {
+		report.Status = BudgetExhaustedStatus
+	}
+
+	if r.cancellationReason != "" {
+		report.Status = CancelledStatus
+	}
+
+	for _, usage := range r.usages {
+
+		report.Totals.add(usage)
+		addTo(report.ByStage, string(usage.Stage), usage)
+		addTo(report.ByFolder, usage.Folder, usage)
+		addTo(report.ByModel, usage.Model, usage)
+
+		if usage.Provider == "" {
+			continue
+		}
+
+		addTo(report.ByProvider, usage.Provider, usage)
+
+		if report.Provenance[usage.Folder] == nil {
+			report.Provenance[usage.Folder] = make(map[string][]string)
+		}
+
+		if providers := report.Provenance[usage.Folder][string(usage.Stage)]; !slices.Contains(providers, usage.Provider) {
+			report.Provenance[usage.Folder][string(usage.Stage)] = append(providers, usage.Provider)
+		}
+	}
+
+	return report
+}
+
+// Write writes the JSON report of the run and prints its summary.
+func (r *ReportService) Write() error {
+
+	report := r.build()
+
+	filename := fmt.Sprintf("report-%s.json", r.startedAt.Format("2006-01-02T15-04-05"))
+
+	content, err := json.MarshalIndent(report, "", "  ")
+
+	if err != nil {
+		return fmt.Errorf("unable to encode the report: %v", err)
+	}
+
+	if err := os.WriteFile(filename, content, 0644); err != nil {
+		return fmt.Errorf("unable to write the report %s: %v", filename, err)
+	}
+
+	r.printSummary(report)
+
+	fmt.Printf("Report written to %s\n", filename)
+
+	return nil
+}
+
+func (r *ReportService) printSummary(report Report) {
+
+	fmt.Println("AI usage summary")
+
+	printTotals := func(label string, totals UsageTotals) {
+		fmt.Printf("  %-40s %5d request(s) %5d cache hit(s) %10d input (%d cached) %10d output tokens %10.4f %s\n",
+			label, totals.Requests, totals.CacheHits, totals.InputTokens, totals.CachedInputTokens, totals.OutputTokens, totals.Cost, report.Currency)
+	}
+
+	for _, folder := range slices.Sorted(maps.Keys(report.ByFolder)) {
+		printTotals(filepath.Base(folder), report.ByFolder[folder])
+	}
+
+	for _, stage := range slices.Sorted(maps.Keys(report.ByStage)) {
+		printTotals(stage, report.ByStage[stage])
+	}
+
+	//	The providers are only worth listing once some requests failed over
+	if len(report.ByProvider) > 1 {
+		for _, provider := range slices.Sorted(maps.Keys(report.ByProvider)) {
+			printTotals(provider, report.ByProvider[provider])
+		}
+	}
+
+	for _, model := range slices.Sorted(maps.Keys(report.ByModel)) {
+		if _, found := r.prices[model]; !found {
+			fmt.Printf("  No price is known for the model '%s', its cost is not accounted\n", model)
+		}
+	}
+
+	printTotals("Total", report.Totals)
+
+	if report.Totals.Repairs > 0 || report.Totals.Reasks > 0 {
+		fmt.Printf("  %d response(s) repaired, %d asked again\n", report.Totals.Repairs, report.Totals.Reasks)
+	}
+
+	fmt.Printf("Run status: %s\n", report.Status)
+
+	if report.BudgetExhaustedReason != "" {
+		fmt.Printf("  Budget exhausted: %s\n", report.BudgetExhaustedReason)
+	}
+
+	if report.CancellationReason != "" {
+		fmt.Printf("  Cancelled: %s\n", report.CancellationReason)
+	}
+
+	if len(report.UnprocessedFolders) > 0 {
+		fmt.Printf("  %d folder(s) not processed:\n", len(report.UnprocessedFolders))
+		for _, unprocessedFolder := range report.UnprocessedFolders {
+			fmt.Printf("    %s (%s)\n", unprocessedFolder.Folder, unprocessedFolder.Reason)
+		}
+	}
+
+	if len(report.FailedFolders) > 0 {
+		fmt.Printf("  %d folder(s) failed:\n", len(report.FailedFolders))
+		for _, failedFolder := range report.FailedFolders {
+			fmt.Printf("    %s at the %s stage after %d attempt(s): %s\n", failedFolder.Folder, failedFolder.Stage, failedFolder.Attempts, failedFolder.Error)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"organizer/internal/abstractions/entities"
+)
+
+// ReviewService queues the folders whose processing needs a human decision. They are left aside
+// by the pipeline, unless the answer could be repaired, and written to a review queue file at the end of the run.
+type ReviewService struct {
+	mutex     sync.Mutex
+	startedAt time.Time
+	items     []ReviewItem
+}
+
+// ReviewItem is a folder waiting for a review, with why it was queued and the candidate answers.
+type ReviewItem struct {
+	Timestamp  time.Time        `json:"timestamp"`
+	Folder     string           `json:"folder"`
+	Stage      entities.AiStage `json:"stage"`
+	Reason     string           `json:"reason"`
+	Details    []string         `json:"details,omitempty"`
+	Candidates any              `json:"candidates,omitempty"`
+}
+
+func New() *ReviewService {
+	return &ReviewService{
+		startedAt: time.Now(),
+	}
+}
+
+// Add queues a folder for review.
+func (r *ReviewService) Add(item ReviewItem) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if item.Timestamp.IsZero() {
+		item.Timestamp = time.Now()
+	}
+
+	r.items = append(r.items, item)
+}
+
+// Items returns the folders queued so far.
+func (r *ReviewService) Items() []ReviewItem {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return slices.Clone(r.items)
+}
+
+// Write writes the review queue of the run, if any folder was queued, and prints its summary.
+func (r *ReviewService) Write() error {
+
+	items := r.Items()
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	filename := fmt.Sprintf("review-%s.json", r.startedAt.Format("2006-01-02T15-04-05"))
+
+	content, err := json.MarshalIndent(items, "", "  ")
+
+	if err != nil {
+		return fmt.Errorf("unable to encode the review queue: %v", err)
+	}
+
+	if err := os.WriteFile(filename, content, 0644); err != nil {
+		return fmt.Errorf("unable to write the review queue %s: %v", filename, err)
+	}
+
+	fmt.Printf("%d folder(s) to review:\n", len(items))
+
+	for _, item := range items {
+		fmt.Printf("  %s (%s)\n", item.Folder, item.Reason)
+	}
+
+	fmt.Printf("Review queue written to %s\n", filename)
+
+	return nil
+}
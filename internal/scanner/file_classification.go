@@ -0,0 +1,78 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"organizer/internal/abstractions/entities"
+	"organizer/internal/imaging"
+)
+
+// sidecarExtensions are the extensions of the notes and checksums accompanying the pages, which the copier carries
+// along with them.
+var sidecarExtensions = []string{".txt", ".md", ".nfo", ".md5", ".sha1", ".sha256", ".sha512", ".sfv"}
+
+// folderFiles are the files of an issue folder, sorted out.
+type folderFiles struct {
+	pages []os.DirEntry
+	//	Names of the notes and checksums of the folder
+	sidecars []string
+}
+
+// classifyFiles sorts out the files of a folder. The pages are recognized from their content rather than their
+// extension, the notes and checksums are kept as sidecars, and anything else is skipped with an audit entry.
+func (s *ScannerService) classifyFiles(folder issueFolder, entries []os.DirEntry) folderFiles {
+
+	var files folderFiles
+
+	skip := func(entry os.DirEntry, reason string) {
+		s.auditService.Log(entities.Audit{Severity: entities.Information, Timestamp: time.Now(), Text: fmt.Sprintf("File '%s' of folder '%s' skipped: %s", entry.Name(), folder.name, reason)})
+	}
+
+	for _, entry := range entries {
+
+		switch {
+		case entry.IsDir():
+			skip(entry, "it is a folder")
+			continue
+		case !entry.Type().IsRegular():
+			skip(entry, "it is not a regular file")
+			continue
+		case isSystemFile(entry.Name()):
+			skip(entry, "it is a system file")
+			continue
+		}
+
+		isPage, mimeType, err := isPageFile(filepath.Join(folder.path, entry.Name()))
+
+		switch {
+		case err != nil:
+			skip(entry, fmt.Sprintf("it cannot be read: %v", err))
+		case isPage:
+			files.pages = append(files.pages, entry)
+		case slices.Contains(sidecarExtensions, strings.ToLower(filepath.Ext(entry.Name()))):
+			s.auditService.Log(entities.Audit{Severity: entities.Debug, Timestamp: time.Now(), Text: fmt.Sprintf("File '%s' of folder '%s' kept as a sidecar", entry.Name(), folder.name)})
+			files.sidecars = append(files.sidecars, entry.Name())
+		default:
+			skip(entry, fmt.Sprintf("its content is not a supported page format (%s)", mimeType))
+		}
+	}
+
+	return files
+}
+
+// isPageFile tells whether the content of the file is in a page format, along with the format it was detected as.
+func isPageFile(path string) (bool, string, error) {
+
+	mimeType, err := imaging.DetectFileMimeType(path)
+
+	if err != nil {
+		return false, "", err
+	}
+
+	return slices.Contains(imaging.PageMimeTypes, mimeType), mimeType, nil
+}
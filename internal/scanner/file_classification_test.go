@@ -0,0 +1,82 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestIsPageFile(t *testing.T) {
+
+	directory := t.TempDir()
+
+	for file, content := range map[string]string{
+		"page.jpg":         "\xff\xd8\xff\xe0\x00\x10JFIF\x00",
+		"page without ext": "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+		"scan.tif":         "II*\x00\x08\x00\x00\x00",
+		"issue.pdf":        "%PDF-1.7\n",
+		"notes.jpg":        "Scanned in 1999",
+	} {
+		if err := os.WriteFile(filepath.Join(directory, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for file, expected := range map[string]bool{
+		"page.jpg":         true,
+		"page without ext": true,
+		"scan.tif":         true,
+		"issue.pdf":        true,
+		"notes.jpg":        false,
+	} {
+		isPage, mimeType, err := isPageFile(filepath.Join(directory, file))
+
+		if err != nil || isPage != expected {
+			t.Errorf("expected '%s' to be a page: %t, got %t (%s, %v)", file, expected, isPage, mimeType, err)
+		}
+	}
+
+	if _, _, err := isPageFile(filepath.Join(directory, "missing.jpg")); err == nil {
+		t.Error("expected a missing file to fail")
+	}
+}
+
+func TestClassifyFilesKeepsThePdfPages(t *testing.T) {
+
+	s := newTestScanner(t)
+	folder := issueFolder{path: t.TempDir(), name: "Issue 12"}
+
+	for file, content := range map[string]string{
+		"page 1.pdf": "%PDF-1.7\n",
+		"page 2.pdf": "%PDF-1.4\n",
+		"issue.md5":  "d41d8cd98f00b204e9800998ecf8427e  page 1.pdf",
+		"Thumbs.db":  "\xd0\xcf\x11\xe0",
+		"notes.doc":  "\xd0\xcf\x11\xe0",
+	} {
+		if err := os.WriteFile(filepath.Join(folder.path, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(folder.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := s.classifyFiles(folder, entries)
+
+	var pages []string
+
+	for _, page := range files.pages {
+		pages = append(pages, page.Name())
+	}
+
+	if expected := []string{"page 1.pdf", "page 2.pdf"}; !slices.Equal(pages, expected) {
+		t.Errorf("expected the pages %v, got %v", expected, pages)
+	}
+
+	if expected := []string{"issue.md5"}; !slices.Equal(files.sidecars, expected) {
+		t.Errorf("expected the sidecars %v, got %v", expected, files.sidecars)
+	}
+}
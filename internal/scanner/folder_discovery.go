@@ -0,0 +1,146 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"organizer/internal/abstractions/entities"
+	"organizer/internal/configuration"
+	"organizer/internal/copier"
+)
+
+// issueFolder is a folder found by the discovery, holding the pages of an issue.
+type issueFolder struct {
+	//	Folder holding the page files, the pages subfolder of the issue when it has one
+	path string
+	//	Path of the issue relative to the working directory, such as 'Series/1998/Issue 12'
+	name string
+}
+
+// discoverIssueFolders searches the working directory for the issue folders, down to the configured depth. The
+// folders of the last level are taken as issues whatever they hold, their subfolders being ignored; above it, a
+// folder is an issue when it holds pages and, following the issue rule, has no subfolder left to search ('leaf')
+// or not ('images'). A folder with a pages subfolder is an issue whose pages are read from that subfolder. The
+// excluded folders and the output folders of the copier are never searched. A folder that cannot be read is recorded
+// as failed and the discovery goes on with the others.
+func (s *ScannerService) discoverIssueFolders() ([]issueFolder, error) {
+
+	var issues []issueFolder
+
+	if err := s.discoverFolder(s.workingDirectory, "", 0, &issues); err != nil {
+		return nil, err
+	}
+
+	return issues, nil
+}
+
+func (s *ScannerService) discoverFolder(directory string, name string, depth int, issues *[]issueFolder) error {
+
+	entries, err := os.ReadDir(directory)
+
+	if err != nil && depth == 0 {
+		return fmt.Errorf("unable to read the folder '%s': %v", directory, err)
+	}
+
+	if err != nil {
+		s.recordFailure(issueFolder{path: directory, name: name}, discoveryStage, fmt.Errorf("unable to read the folder: %v", err))
+		return nil
+	}
+
+	var subfolders []os.DirEntry
+	holdsPages := false
+
+	for _, entry := range entries {
+		switch {
+		case entry.IsDir() && !s.isExcludedFolder(entry.Name(), depth):
+			subfolders = append(subfolders, entry)
+		case !holdsPages && entry.Type().IsRegular() && !isSystemFile(entry.Name()):
+			//	Recognized from their content like the pages of the issue folders, an unreadable file being left to
+			//	the listing of the folder
+			holdsPages, _, _ = isPageFile(filepath.Join(directory, entry.Name()))
+		}
+	}
+
+	if depth > 0 {
+
+		if pagesFolder := s.pagesFolderOf(subfolders); pagesFolder != nil {
+			s.addIssueFolder(issueFolder{path: filepath.Join(directory, pagesFolder.Name()), name: name}, issues)
+			return nil
+		}
+
+		if depth == s.discovery.Depth {
+			s.addIssueFolder(issueFolder{path: directory, name: name}, issues)
+			return nil
+		}
+
+		if holdsPages && (len(subfolders) == 0 || s.discovery.IssueRule == configuration.ImagesIssueRule) {
+			s.addIssueFolder(issueFolder{path: directory, name: name}, issues)
+			return nil
+		}
+
+		if holdsPages {
+			s.auditService.Log(entities.Audit{Severity: entities.Warning, Timestamp: time.Now(), Text: fmt.Sprintf("Folder '%s' holds pages but also subfolders, its pages are ignored", name)})
+		}
+	}
+
+	for _, subfolder := range subfolders {
+		if err := s.discoverFolder(filepath.Join(directory, subfolder.Name()), filepath.Join(name, subfolder.Name()), depth+1, issues); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isExcludedFolder tells whether a folder is never searched.
+func (s *ScannerService) isExcludedFolder(folderName string, depth int) bool {
+
+	//	The magazines written by the copier
+	if depth == 0 && strings.HasPrefix(folderName, copier.Prefix) {
+		return true
+	}
+
+	return matchesAny(folderName, s.discovery.Exclude)
+}
+
+// pagesFolderOf returns the pages subfolder among the subfolders of a folder, if any.
+func (s *ScannerService) pagesFolderOf(subfolders []os.DirEntry) os.DirEntry {
+
+	if s.discovery.PagesFolder == "" {
+		return nil
+	}
+
+	for _, subfolder := range subfolders {
+		if strings.EqualFold(subfolder.Name(), s.discovery.PagesFolder) {
+			return subfolder
+		}
+	}
+
+	return nil
+}
+
+// addIssueFolder adds an issue folder unless its name does not match the include patterns.
+func (s *ScannerService) addIssueFolder(issue issueFolder, issues *[]issueFolder) {
+
+	if len(s.discovery.Include) > 0 && !matchesAny(filepath.Base(issue.name), s.discovery.Include) {
+		s.auditService.Log(entities.Audit{Severity: entities.Debug, Timestamp: time.Now(), Text: fmt.Sprintf("Folder '%s' not included", issue.name)})
+		return
+	}
+
+	*issues = append(*issues, issue)
+}
+
+func matchesAny(folderName string, patterns []string) bool {
+
+	for _, pattern := range patterns {
+		//	The patterns were validated with the configuration
+		if matched, _ := filepath.Match(pattern, folderName); matched {
+			return true
+		}
+	}
+
+	return false
+}
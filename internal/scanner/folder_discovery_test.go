@@ -0,0 +1,65 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"organizer/internal/audit"
+	"organizer/internal/configuration"
+)
+
+func TestDiscoveryRecognizesThePagesFromTheirContent(t *testing.T) {
+
+	//	The audit log is written to the current directory
+	t.Chdir(t.TempDir())
+
+	workingDirectory := "scans"
+
+	//	'A' holds a page without extension, 'B' only a note named as a page, both of them having a subfolder, and 'C'
+	//	PDF pages
+	for file, content := range map[string]string{
+		"A/cover":       "\xff\xd8\xff\xe0\x00\x10JFIF\x00",
+		"A/extras/note": "Scanned in 1999",
+		"B/cover.jpg":   "Scanned in 1999",
+		"B/1/page.jpg":  "\xff\xd8\xff\xe0\x00\x10JFIF\x00",
+		"C/page 1.pdf":  "%PDF-1.7\n",
+	} {
+		path := filepath.Join(workingDirectory, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	auditService, err := audit.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &ScannerService{
+		workingDirectory: workingDirectory,
+		discovery:        configuration.DiscoverySettings{Depth: 2, IssueRule: configuration.ImagesIssueRule},
+		auditService:     auditService,
+	}
+
+	issues, err := s.discoverIssueFolders()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+
+	for _, issue := range issues {
+		names = append(names, issue.name)
+	}
+
+	slices.Sort(names)
+
+	if expected := []string{"A", filepath.Join("B", "1"), "C"}; !slices.Equal(names, expected) {
+		t.Errorf("expected the issue folders %v, got %v", expected, names)
+	}
+}
@@ -0,0 +1,281 @@
+package scanner
+
+import (
+	"cmp"
+	"math"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"organizer/internal/abstractions/entities"
+)
+
+const (
+	//	Smallest date stamp, in the 'yyyymmdd' form
+	minimumStamp = 19000101
+	//	Most pages an issue can have, the pages being numbered on a byte
+	maxPages = math.MaxUint8
+)
+
+var (
+	digitRuns = regexp.MustCompile(`\d+`)
+	//	Copies made by the file managers, such as 'Scan 12 1.jpg' or 'IMG_0042 (1).jpg', the copied name ending with a number
+	duplicateSuffix = regexp.MustCompile(`(?i)^(.*\d)(?: 1| \(1\)| copy| - copy| copie| - copie)$`)
+	//	Files the operating systems leave in the folders
+	systemFiles = []string{"thumbs.db", "desktop.ini"}
+)
+
+// localOrdering is the order of the pages of a folder inferred from their file names alone.
+type localOrdering struct {
+	pages []entities.MagazinePage
+	//	Shape of the names the pages follow, their digit runs being replaced by '#'
+	scheme string
+	//	From 0 to 1, lowered by the gaps in the counters, and 0 when pages are left out
+	confidence float64
+	//	Copies of other files, left out on purpose
+	excluded []string
+	//	Pages not following the scheme, which only the AI can place
+	leftOut []string
+}
+
+// pageName is a file name broken down for the ordering.
+type pageName struct {
+	file    string
+	shape   string
+	numbers []int
+}
+
+func newPageName(file string) pageName {
+
+	stem := strings.TrimSuffix(file, filepath.Ext(file))
+
+	name := pageName{
+		file:  file,
+		shape: strings.ToLower(digitRuns.ReplaceAllString(stem, "#") + filepath.Ext(file)),
+	}
+
+	for _, run := range digitRuns.FindAllString(stem, -1) {
+		number, _ := strconv.Atoi(run)
+		name.numbers = append(name.numbers, number)
+	}
+
+	return name
+}
+
+// orderPagesLocally recognizes the naming scheme of the scanners, zero-padded or not counters ('page_001', 'Scan 12',
+// 'IMG_0042') and date-time stamps, and sorts the files naturally. The copies ending with ' 1' and the system files
+// are excluded. The pages not following the scheme of most of the others, such as a 'Cover.jpg' among 'page_001.jpg'
+// and the next ones, cannot be placed without a guess: they are left out and the ordering gets no confidence, as does
+// a folder with more pages than an issue can have.
+func orderPagesLocally(fileNames []string) localOrdering {
+
+	var ordering localOrdering
+
+	fileNames, ordering.excluded = splitDuplicateCopies(fileNames)
+
+	var names []pageName
+	shapeCounts := make(map[string]int)
+
+	for _, file := range fileNames {
+
+		if isSystemFile(file) {
+			continue
+		}
+
+		name := newPageName(file)
+
+		names = append(names, name)
+		shapeCounts[name.shape]++
+	}
+
+	if len(names) == 0 {
+		return ordering
+	}
+
+	//	The most common shape, the first one in alphabetical order winning the ties
+	for shape, count := range shapeCounts {
+		if count > shapeCounts[ordering.scheme] || count == shapeCounts[ordering.scheme] && shape < ordering.scheme {
+			ordering.scheme = shape
+		}
+	}
+
+	var pages []pageName
+
+	for _, name := range names {
+
+		switch {
+		case name.shape == ordering.scheme:
+			pages = append(pages, name)
+		case isUnnumberedFirst(name, ordering.scheme):
+			//	'Scan.jpg' comes before 'Scan 1.jpg'
+			name.numbers = []int{-1}
+			pages = append(pages, name)
+		default:
+			ordering.leftOut = append(ordering.leftOut, name.file)
+		}
+	}
+
+	if len(pages) > maxPages {
+		return ordering
+	}
+
+	slices.SortStableFunc(pages, func(a pageName, b pageName) int {
+		return cmp.Or(slices.Compare(a.numbers, b.numbers), naturalCompare(a.file, b.file))
+	})
+
+	for index, page := range pages {
+		ordering.pages = append(ordering.pages, entities.MagazinePage{File: page.file, Number: uint8(index + 1)})
+	}
+
+	//	Nothing to sort the pages on, or pages which cannot be placed
+	if !strings.Contains(ordering.scheme, "#") || len(ordering.leftOut) > 0 {
+		return ordering
+	}
+
+	ordering.confidence = 1
+
+	if isCounter(ordering.scheme, pages) {
+		ordering.confidence -= 0.5 * float64(counterGaps(pages)) / float64(len(pages))
+	}
+
+	return ordering
+}
+
+// stemsOf returns the lowercase names of the files without their extension.
+func stemsOf(fileNames []string) map[string]bool {
+
+	stems := make(map[string]bool, len(fileNames))
+
+	for _, file := range fileNames {
+		stems[strings.ToLower(strings.TrimSuffix(file, filepath.Ext(file)))] = true
+	}
+
+	return stems
+}
+
+// splitDuplicateCopies separates the copies of other files of the folder from the files which may be pages.
+func splitDuplicateCopies(fileNames []string) ([]string, []string) {
+
+	var pages []string
+	var duplicates []string
+
+	stems := stemsOf(fileNames)
+
+	for _, file := range fileNames {
+		if isDuplicateCopy(file, stems) {
+			duplicates = append(duplicates, file)
+		} else {
+			pages = append(pages, file)
+		}
+	}
+
+	return pages, duplicates
+}
+
+// isDuplicateCopy tells whether the file is the copy of another file of the folder.
+func isDuplicateCopy(file string, stems map[string]bool) bool {
+
+	match := duplicateSuffix.FindStringSubmatch(strings.TrimSuffix(file, filepath.Ext(file)))
+
+	return match != nil && stems[strings.ToLower(match[1])]
+}
+
+func isSystemFile(file string) bool {
+	return strings.HasPrefix(file, ".") || slices.Contains(systemFiles, strings.ToLower(file))
+}
+
+// isUnnumberedFirst tells whether the name is the one of the scheme without its single number, which some scanners
+// give to the first page.
+func isUnnumberedFirst(name pageName, scheme string) bool {
+
+	if len(name.numbers) > 0 || strings.Count(scheme, "#") != 1 {
+		return false
+	}
+
+	before, after, _ := strings.Cut(scheme, "#")
+
+	return name.shape == strings.TrimRight(before, " _-")+after
+}
+
+// isCounter tells whether the scheme numbers its pages with a single counter, rather than with a date-time stamp
+// such as '20240105143012'.
+func isCounter(scheme string, pages []pageName) bool {
+
+	if strings.Count(scheme, "#") != 1 {
+		return false
+	}
+
+	for _, page := range pages {
+		if page.numbers[0] >= minimumStamp {
+			return false
+		}
+	}
+
+	return true
+}
+
+// counterGaps counts the missing and the repeated numbers between consecutive pages.
+func counterGaps(pages []pageName) int {
+
+	gaps := 0
+
+	for index := 1; index < len(pages); index++ {
+
+		previous, current := pages[index-1].numbers[0], pages[index].numbers[0]
+
+		if previous >= 0 && current-previous != 1 {
+			gaps++
+		}
+	}
+
+	return gaps
+}
+
+// naturalCompare compares the names case-insensitively, their digit runs by value.
+func naturalCompare(a string, b string) int {
+
+	aChunks, bChunks := naturalChunks(a), naturalChunks(b)
+
+	for index := 0; index < min(len(aChunks), len(bChunks)); index++ {
+
+		aNumber, aErr := strconv.Atoi(aChunks[index])
+		bNumber, bErr := strconv.Atoi(bChunks[index])
+
+		var result int
+
+		if aErr == nil && bErr == nil {
+			result = cmp.Compare(aNumber, bNumber)
+		} else {
+			result = strings.Compare(strings.ToLower(aChunks[index]), strings.ToLower(bChunks[index]))
+		}
+
+		if result != 0 {
+			return result
+		}
+	}
+
+	return cmp.Compare(len(aChunks), len(bChunks))
+}
+
+// naturalChunks splits a name into its digit runs and the text between them.
+func naturalChunks(name string) []string {
+
+	var chunks []string
+	last := 0
+
+	for _, run := range digitRuns.FindAllStringIndex(name, -1) {
+		if run[0] > last {
+			chunks = append(chunks, name[last:run[0]])
+		}
+		chunks = append(chunks, name[run[0]:run[1]])
+		last = run[1]
+	}
+
+	if last < len(name) {
+		chunks = append(chunks, name[last:])
+	}
+
+	return chunks
+}
@@ -0,0 +1,154 @@
+package scanner
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"organizer/internal/abstractions/entities"
+)
+
+func TestOrderPagesLocally(t *testing.T) {
+
+	for _, test := range []struct {
+		name      string
+		files     []string
+		pages     []string
+		excluded  []string
+		leftOut   []string
+		confident bool
+	}{
+		{
+			name:      "zero-padded counter",
+			files:     []string{"page_003.jpg", "page_001.jpg", "page_002.jpg"},
+			pages:     []string{"page_001.jpg", "page_002.jpg", "page_003.jpg"},
+			confident: true,
+		},
+		{
+			name:      "unnumbered first page and a gap in the counter",
+			files:     []string{"Scan 10.jpg", "Scan 9.jpg", "Scan.jpg", "Scan 1.jpg"},
+			pages:     []string{"Scan.jpg", "Scan 1.jpg", "Scan 9.jpg", "Scan 10.jpg"},
+			confident: false,
+		},
+		{
+			name:      "copies and system files",
+			files:     []string{"IMG_0002.jpg", "IMG_0001.jpg", "IMG_0001 (1).jpg", "Thumbs.db", ".DS_Store"},
+			pages:     []string{"IMG_0001.jpg", "IMG_0002.jpg"},
+			excluded:  []string{"IMG_0001 (1).jpg"},
+			confident: true,
+		},
+		{
+			name:      "date-time stamps",
+			files:     []string{"20240105143512.jpg", "20240105143012.jpg"},
+			pages:     []string{"20240105143012.jpg", "20240105143512.jpg"},
+			confident: true,
+		},
+		{
+			name:    "page not following the scheme",
+			files:   append([]string{"Cover.jpg"}, pageFiles(40)...),
+			pages:   pageFiles(40),
+			leftOut: []string{"Cover.jpg"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+
+			ordering := orderPagesLocally(test.files)
+
+			if files := filesOf(ordering.pages); !slices.Equal(files, test.pages) {
+				t.Errorf("expected the pages %v, got %v", test.pages, files)
+			}
+
+			for index, page := range ordering.pages {
+				if page.Number != uint8(index+1) {
+					t.Errorf("expected '%s' to be the page %d, got %d", page.File, index+1, page.Number)
+				}
+			}
+
+			if !slices.Equal(ordering.excluded, test.excluded) || !slices.Equal(ordering.leftOut, test.leftOut) {
+				t.Errorf("expected %v excluded and %v left out, got %v and %v", test.excluded, test.leftOut, ordering.excluded, ordering.leftOut)
+			}
+
+			if confident := ordering.confidence >= 0.9; confident != test.confident {
+				t.Errorf("expected the confidence to be above 0.9: %t, got %.3f", test.confident, ordering.confidence)
+			}
+		})
+	}
+}
+
+func TestOrderPagesLocallyRefusesTooManyPages(t *testing.T) {
+
+	ordering := orderPagesLocally(pageFiles(maxPages + 1))
+
+	if len(ordering.pages) != 0 || ordering.confidence != 0 {
+		t.Errorf("expected %d pages not to be ordered, got %d page(s) with the confidence %.3f", maxPages+1, len(ordering.pages), ordering.confidence)
+	}
+
+	if ordering := orderPagesLocally(pageFiles(maxPages)); len(ordering.pages) != maxPages || ordering.pages[maxPages-1].Number != maxPages {
+		t.Errorf("expected %d pages to be ordered", maxPages)
+	}
+}
+
+func TestNaturalCompare(t *testing.T) {
+
+	for _, test := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"page 2.jpg", "page 10.jpg", -1},
+		{"page 10.jpg", "page 2.jpg", 1},
+		{"Page 2.jpg", "page 2.jpg", 0},
+		{"page 002.jpg", "page 2.jpg", 0},
+		{"a.jpg", "b.jpg", -1},
+		{"scan.jpg", "scan 1.jpg", 1},
+		{"scan", "scan 1", -1},
+	} {
+		if result := naturalCompare(test.a, test.b); result != test.expected {
+			t.Errorf("expected naturalCompare('%s', '%s') to be %d, got %d", test.a, test.b, test.expected, result)
+		}
+	}
+}
+
+func TestIsDuplicateCopy(t *testing.T) {
+
+	stems := stemsOf([]string{"Scan 12.jpg", "IMG_0042.jpg", "page 3.png"})
+
+	for file, expected := range map[string]bool{
+		"Scan 12 1.jpg":       true,
+		"IMG_0042 (1).jpg":    true,
+		"IMG_0042 copy.jpg":   true,
+		"page 3 - Copie.png":  true,
+		"Scan 12.jpg":         false,
+		"Scan 13 1.jpg":       false,
+		"IMG_0043 (1).jpg":    false,
+		"Scan 1.jpg":          false,
+		"cover (1).jpg":       false,
+		"page 3 - copy 2.png": false,
+	} {
+		if duplicate := isDuplicateCopy(file, stems); duplicate != expected {
+			t.Errorf("expected isDuplicateCopy('%s') to be %t, got %t", file, expected, duplicate)
+		}
+	}
+}
+
+// pageFiles returns the names 'page_001.jpg' to the given page.
+func pageFiles(count int) []string {
+
+	files := make([]string, 0, count)
+
+	for page := 1; page <= count; page++ {
+		files = append(files, fmt.Sprintf("page_%03d.jpg", page))
+	}
+
+	return files
+}
+
+func filesOf(pages []entities.MagazinePage) []string {
+
+	files := make([]string, 0, len(pages))
+
+	for _, page := range pages {
+		files = append(files, page.File)
+	}
+
+	return files
+}
@@ -0,0 +1,119 @@
+package scanner
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"organizer/internal/abstractions/entities"
+)
+
+// pageReconciliation is the page list answered by the AI once checked against the files of the folder.
+type pageReconciliation struct {
+	pages []entities.MagazinePage
+	//	Page files of the folder the answer leaves out
+	missing []string
+	//	Files of the folder the answer lists although they are not pages, such as the copies of other pages
+	extra []string
+	//	Files the answer lists although the folder does not have them
+	hallucinated []string
+	//	Deterministic repairs applied to the answer
+	repairs []string
+}
+
+// reconcilePages checks the pages answered by the AI against the page files of the folder, the duplicates being the
+// copies the prompt left out. The files listed twice, the extra and the hallucinated ones are removed, a file listed
+// with another case is matched to the real one, and the pages are renumbered from 1 without gaps.
+func reconcilePages(answer []entities.MagazinePage, files []os.DirEntry, duplicates []string) pageReconciliation {
+
+	var reconciliation pageReconciliation
+
+	entries := make(map[string]os.DirEntry, len(files))
+	entriesByLowerName := make(map[string][]os.DirEntry, len(files))
+
+	for _, file := range files {
+		entries[file.Name()] = file
+		entriesByLowerName[strings.ToLower(file.Name())] = append(entriesByLowerName[strings.ToLower(file.Name())], file)
+	}
+
+	isPage := func(file os.DirEntry) bool {
+		return !slices.Contains(duplicates, file.Name())
+	}
+
+	sortedAnswer := slices.Clone(answer)
+
+	slices.SortStableFunc(sortedAnswer, func(a entities.MagazinePage, b entities.MagazinePage) int {
+		return cmp.Compare(a.Number, b.Number)
+	})
+
+	listed := make(map[string]bool)
+
+	for _, page := range sortedAnswer {
+
+		entry, found := entries[page.File]
+
+		if !found {
+			if candidates := entriesByLowerName[strings.ToLower(page.File)]; len(candidates) == 1 {
+				entry, found = candidates[0], true
+				reconciliation.repairs = append(reconciliation.repairs, fmt.Sprintf("'%s' matched to '%s'", page.File, entry.Name()))
+			}
+		}
+
+		switch {
+		case !found:
+			reconciliation.hallucinated = append(reconciliation.hallucinated, page.File)
+		case listed[entry.Name()]:
+			reconciliation.repairs = append(reconciliation.repairs, fmt.Sprintf("'%s' listed again as the page %d removed", entry.Name(), page.Number))
+		case !isPage(entry):
+			listed[entry.Name()] = true
+			reconciliation.extra = append(reconciliation.extra, entry.Name())
+		default:
+			listed[entry.Name()] = true
+			reconciliation.pages = append(reconciliation.pages, entities.MagazinePage{File: entry.Name(), Number: page.Number})
+		}
+	}
+
+	renumbered := false
+
+	for index := range reconciliation.pages {
+		if number := uint8(index + 1); reconciliation.pages[index].Number != number {
+			reconciliation.pages[index].Number = number
+			renumbered = true
+		}
+	}
+
+	if renumbered {
+		reconciliation.repairs = append(reconciliation.repairs, "pages renumbered from 1 without gaps")
+	}
+
+	for _, file := range files {
+		if !listed[file.Name()] && isPage(file) {
+			reconciliation.missing = append(reconciliation.missing, file.Name())
+		}
+	}
+
+	return reconciliation
+}
+
+// details lists the differences between the answer and the folder, for the review.
+func (r pageReconciliation) details() []string {
+
+	var details []string
+
+	for _, difference := range []struct {
+		label string
+		files []string
+	}{
+		{label: "missing", files: r.missing},
+		{label: "extra", files: r.extra},
+		{label: "hallucinated", files: r.hallucinated},
+	} {
+		if len(difference.files) > 0 {
+			details = append(details, fmt.Sprintf("%s: %s", difference.label, strings.Join(difference.files, ", ")))
+		}
+	}
+
+	return details
+}
@@ -0,0 +1,81 @@
+package scanner
+
+import (
+	"slices"
+	"testing"
+
+	"organizer/internal/abstractions/entities"
+)
+
+func TestReconcilePages(t *testing.T) {
+
+	//	'page_002 - Copy (2).jpg' is a copy the prompt left out, which the file names alone would not tell
+	_, files := newTestFolder(t, []string{"page_001.jpg", "page_002.jpg", "page_003.jpg", "page_002 - Copy (2).jpg"})
+	duplicates := []string{"page_002 - Copy (2).jpg"}
+
+	for _, test := range []struct {
+		name         string
+		answer       []entities.MagazinePage
+		pages        []string
+		missing      []string
+		extra        []string
+		hallucinated []string
+		repairs      []string
+	}{
+		{
+			name:   "every page",
+			answer: []entities.MagazinePage{{File: "page_001.jpg", Number: 1}, {File: "page_002.jpg", Number: 2}, {File: "page_003.jpg", Number: 3}},
+			pages:  []string{"page_001.jpg", "page_002.jpg", "page_003.jpg"},
+		},
+		{
+			name:    "missing page",
+			answer:  []entities.MagazinePage{{File: "page_001.jpg", Number: 1}, {File: "page_003.jpg", Number: 3}},
+			pages:   []string{"page_001.jpg", "page_003.jpg"},
+			missing: []string{"page_002.jpg"},
+			repairs: []string{"pages renumbered from 1 without gaps"},
+		},
+		{
+			name:    "copy listed",
+			answer:  []entities.MagazinePage{{File: "page_001.jpg", Number: 1}, {File: "page_002.jpg", Number: 2}, {File: "page_002 - Copy (2).jpg", Number: 3}, {File: "page_003.jpg", Number: 4}},
+			pages:   []string{"page_001.jpg", "page_002.jpg", "page_003.jpg"},
+			extra:   []string{"page_002 - Copy (2).jpg"},
+			repairs: []string{"pages renumbered from 1 without gaps"},
+		},
+		{
+			name:    "page listed twice",
+			answer:  []entities.MagazinePage{{File: "page_001.jpg", Number: 1}, {File: "page_002.jpg", Number: 2}, {File: "page_002.jpg", Number: 3}, {File: "page_003.jpg", Number: 4}},
+			pages:   []string{"page_001.jpg", "page_002.jpg", "page_003.jpg"},
+			repairs: []string{"'page_002.jpg' listed again as the page 3 removed", "pages renumbered from 1 without gaps"},
+		},
+		{
+			name:         "hallucinated and miscased pages",
+			answer:       []entities.MagazinePage{{File: "Page_001.JPG", Number: 1}, {File: "page_002.jpg", Number: 2}, {File: "page_003.jpg", Number: 3}, {File: "page_004.jpg", Number: 4}},
+			pages:        []string{"page_001.jpg", "page_002.jpg", "page_003.jpg"},
+			hallucinated: []string{"page_004.jpg"},
+			repairs:      []string{"'Page_001.JPG' matched to 'page_001.jpg'"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+
+			reconciliation := reconcilePages(test.answer, files, duplicates)
+
+			if pages := filesOf(reconciliation.pages); !slices.Equal(pages, test.pages) {
+				t.Errorf("expected the pages %v, got %v", test.pages, pages)
+			}
+
+			for index, page := range reconciliation.pages {
+				if page.Number != uint8(index+1) {
+					t.Errorf("expected '%s' to be the page %d, got %d", page.File, index+1, page.Number)
+				}
+			}
+
+			if !slices.Equal(reconciliation.missing, test.missing) || !slices.Equal(reconciliation.extra, test.extra) || !slices.Equal(reconciliation.hallucinated, test.hallucinated) {
+				t.Errorf("expected %v missing, %v extra and %v hallucinated, got %v, %v and %v", test.missing, test.extra, test.hallucinated, reconciliation.missing, reconciliation.extra, reconciliation.hallucinated)
+			}
+
+			if !slices.Equal(reconciliation.repairs, test.repairs) {
+				t.Errorf("expected the repairs %v, got %v", test.repairs, reconciliation.repairs)
+			}
+		})
+	}
+}
@@ -2,12 +2,16 @@ package scanner
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"organizer/internal/abstractions"
 	"organizer/internal/abstractions/entities"
-	"organizer/internal/ai"
+	"organizer/internal/abstractions/interfaces"
 	"organizer/internal/audit"
 	"organizer/internal/configuration"
+	"organizer/internal/prompts"
+	"organizer/internal/report"
+	"organizer/internal/review"
 	"os"
 	"path/filepath"
 	"strings"
@@ -15,14 +19,30 @@ import (
 	"time"
 )
 
+// errQueuedForReview tells that a folder is left aside for a review.
+var errQueuedForReview = errors.New("the folder is queued for review")
+
+const (
+	//	Stages of the scanner failures, besides the page ordering
+	discoveryStage = "discovery"
+	listingStage   = "listing"
+)
+
 const (
-	AssistantPrompt = "Below are the files found in the directory. Based on the information found there, sort them according to their scanner number in a JSON array (for example: [{\"file\": \"page_01.pdf\", \"number\": 1 }, {\"file\": \"page_02.pdf\", \"number\": 2 }]). If the 1st file starts at the number 0, make sure you start counting at 1. Return only valid JSON and no extra text. Exclude the duplicate file names, especially those who have an extra ' 1', and exclude the files that does not seem to be entirely different from the rest. Make sure the first page is number 1."
+	//	Reason of the folders without any page
+	noPagesReason = "no page found"
 )
 
 type ScannerService struct {
 	workingDirectory     string
-	aiProxy              *ai.AiProxy
+	discovery            configuration.DiscoverySettings
+	localPageOrdering    bool
+	orderingConfidence   float64
+	aiProxy              interfaces.AiProxy
+	promptService        *prompts.PromptService
 	auditService         *audit.AuditService
+	reportService        *report.ReportService
+	reviewService        *review.ReviewService
 	context              context.Context
 	magazinePagesChannel chan entities.MagazinePages
 	waitGroup            *sync.WaitGroup
@@ -30,16 +50,25 @@ type ScannerService struct {
 
 func New(
 	configurationService *configuration.ConfigurationService,
-	aiProxy *ai.AiProxy,
+	aiProxy interfaces.AiProxy,
+	promptService *prompts.PromptService,
 	auditService *audit.AuditService,
+	reportService *report.ReportService,
+	reviewService *review.ReviewService,
 	context context.Context,
 	waitGroup *sync.WaitGroup) *ScannerService {
 
 	service := ScannerService{
 		workingDirectory:     configurationService.WorkingDirectory,
+		discovery:            configurationService.FolderDiscovery,
+		localPageOrdering:    configurationService.LocalPageOrdering,
+		orderingConfidence:   configurationService.OrderingConfidence,
 		context:              context,
 		aiProxy:              aiProxy,
+		promptService:        promptService,
 		auditService:         auditService,
+		reportService:        reportService,
+		reviewService:        reviewService,
 		waitGroup:            waitGroup,
 		magazinePagesChannel: make(chan entities.MagazinePages),
 	}
@@ -65,78 +94,190 @@ func (s *ScannerService) Scan() {
 	}()
 }
 
+// readFolders emits the pages of every issue folder. A folder whose processing fails is recorded in the report and
+// the next ones are processed all the same; the channel is closed whatever happens.
 func (s *ScannerService) readFolders() error {
 
-	folders, err := os.ReadDir(s.workingDirectory)
+	defer func() {
+		close(s.magazinePagesChannel)
+		s.auditService.Log(entities.Audit{Severity: entities.Information, Timestamp: time.Now(), Text: fmt.Sprintf("Scanner service stopped.")})
+	}()
+
+	folders, err := s.discoverIssueFolders()
 
 	if err != nil {
-		return fmt.Errorf("unable to read all the folder from the working directory: %s", err)
+		s.reportService.RecordFailure(s.workingDirectory, discoveryStage, err, 1)
+		return fmt.Errorf("unable to discover the issue folders from the working directory: %s", err)
 	}
 
-	for _, folder := range folders {
+	s.auditService.Log(entities.Audit{Severity: entities.Information, Timestamp: time.Now(), Text: fmt.Sprintf("Found %d issue folder(s) in the working directory", len(folders))})
 
-		if !folder.IsDir() {
-			continue
+	for index, folder := range folders {
+
+		if s.context.Err() != nil {
+			s.skipFolders(folders[index:], report.CancelledStatus)
+			break
 		}
 
-		//	Read all the file names in the directory
-		publicationFolder := filepath.Join(s.workingDirectory, folder.Name())
+		//	The folders ordered locally would not reach the copier either, their cover analysis being refused
+		if s.aiProxy.BudgetExhausted() {
+			s.skipFolders(folders[index:], report.BudgetExhaustedStatus)
+			break
+		}
 
-		files, err := os.ReadDir(publicationFolder)
+		//	Read all the file names in the directory
+		entries, err := os.ReadDir(folder.path)
 
 		if err != nil {
-			return fmt.Errorf("unable to read all the files from the directory: %s", err)
+			s.recordFailure(folder, listingStage, fmt.Errorf("unable to read all the files from the directory: %s", err))
+			continue
 		}
 
-		s.auditService.Log(entities.Audit{Severity: entities.Information, Timestamp: time.Now(), Text: fmt.Sprintf("Analyzing folder '%s'", folder.Name())})
+		s.auditService.Log(entities.Audit{Severity: entities.Information, Timestamp: time.Now(), Text: fmt.Sprintf("Analyzing folder '%s'", folder.name)})
+
+		files := s.classifyFiles(folder, entries)
 
-		//	Ask the LLM to infer file order from file names
-		orderedPages, err := s.getMagazinePages(files)
+		if len(files.pages) == 0 {
+			s.auditService.Log(entities.Audit{Severity: entities.Warning, Timestamp: time.Now(), Text: fmt.Sprintf("Folder '%s' skipped: %s", folder.name, noPagesReason)})
+			s.reportService.RecordUnprocessedFolder(folder.path, noPagesReason)
+			continue
+		}
+
+		//	Infer the file order from the file names, asking the LLM when the names are not clear enough
+		orderedPages, err := s.getMagazinePages(folder, files.pages)
+		if errors.Is(err, abstractions.ErrBudgetExhausted) {
+			s.skipFolders(folders[index:], report.BudgetExhaustedStatus)
+			break
+		}
+		if err != nil && s.context.Err() != nil {
+			s.skipFolders(folders[index:], report.CancelledStatus)
+			break
+		}
+		if errors.Is(err, errQueuedForReview) {
+			continue
+		}
 		if err != nil {
-			return err
+			s.recordFailure(folder, string(entities.PageOrderingStage), err)
+			continue
 		}
 
 		//	Send the ordered pages to the channel for further processing
-		s.auditService.Log(entities.Audit{Severity: entities.Information, Timestamp: time.Now(), Text: fmt.Sprintf("Found %d pages in folder '%s'", len(orderedPages), folder.Name())})
+		s.auditService.Log(entities.Audit{Severity: entities.Information, Timestamp: time.Now(), Text: fmt.Sprintf("Found %d pages in folder '%s'", len(orderedPages), folder.name)})
 
 		magazinePages := entities.MagazinePages{
-			Pages:  orderedPages,
-			Folder: publicationFolder,
+			Pages:    orderedPages,
+			Folder:   folder.path,
+			Sidecars: files.sidecars,
 		}
 
 		s.magazinePagesChannel <- magazinePages
 	}
 
-	close(s.magazinePagesChannel)
+	return nil
+}
 
-	s.auditService.Log(entities.Audit{Severity: entities.Information, Timestamp: time.Now(), Text: fmt.Sprintf("Scanner service stopped.")})
+// recordFailure records a folder whose processing failed at the given stage.
+func (s *ScannerService) recordFailure(folder issueFolder, stage string, err error) {
 
-	return nil
+	attempts := abstractions.AttemptsOf(err)
+
+	s.auditService.Log(entities.Audit{Severity: entities.Error, Timestamp: time.Now(), Text: fmt.Sprintf("Folder '%s' failed at the %s stage after %d attempt(s): %v", folder.name, stage, attempts, err)})
+	s.reportService.RecordFailure(folder.path, stage, err, attempts)
 }
 
-func (s *ScannerService) getMagazinePages(files []os.DirEntry) ([]entities.MagazinePage, error) {
+// skipFolders records the folders that will not be emitted.
+func (s *ScannerService) skipFolders(folders []issueFolder, reason string) {
 
-	var assistantPrompt strings.Builder
-	assistantPrompt.WriteString(AssistantPrompt)
-	assistantPrompt.WriteString("\n")
+	for _, folder := range folders {
+		s.auditService.Log(entities.Audit{Severity: entities.Warning, Timestamp: time.Now(), Text: fmt.Sprintf("Folder '%s' skipped: %s", folder.name, reason)})
+		s.reportService.RecordUnprocessedFolder(folder.path, reason)
+	}
+}
+
+func (s *ScannerService) getMagazinePages(folder issueFolder, files []os.DirEntry) ([]entities.MagazinePage, error) {
+
+	fileNames := make([]string, 0, len(files))
 
 	for _, file := range files {
-		assistantPrompt.WriteString(file.Name())
-		assistantPrompt.WriteString("\n")
+		fileNames = append(fileNames, file.Name())
+	}
+
+	//	The copies of other pages are left out, and do not count
+	pageNames, duplicates := splitDuplicateCopies(fileNames)
+
+	if len(pageNames) > maxPages {
+		return nil, fmt.Errorf("the folder holds %d pages, more than the %d of an issue", len(pageNames), maxPages)
+	}
+
+	if s.localPageOrdering {
+
+		ordering := orderPagesLocally(fileNames)
+
+		if ordering.confidence >= s.orderingConfidence && len(ordering.pages) > 0 && len(ordering.leftOut) == 0 {
+			s.auditService.Log(entities.Audit{Severity: entities.Information, Timestamp: time.Now(), Text: fmt.Sprintf("Pages of folder '%s' ordered from their names following '%s' (confidence %.2f), %d file(s) excluded: %v", folder.name, ordering.scheme, ordering.confidence, len(ordering.excluded), ordering.excluded)})
+			return ordering.pages, nil
+		}
+
+		s.auditService.Log(entities.Audit{Severity: entities.Information, Timestamp: time.Now(), Text: fmt.Sprintf("Pages of folder '%s' not clearly ordered by their names (confidence %.2f, %d page(s) not following '%s': %v), asking the AI", folder.name, ordering.confidence, len(ordering.leftOut), ordering.scheme, ordering.leftOut)})
 	}
 
-	aiResponse, err := s.aiProxy.SendRequest(assistantPrompt.String())
+	assistantPrompt, err := s.promptService.Render(entities.PageOrderingStage, prompts.PromptData{
+		FolderName: filepath.Base(folder.name),
+		Files:      pageNames,
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
 	var orderedPages []entities.MagazinePage
-	if err := json.Unmarshal([]byte(aiResponse), &orderedPages); err != nil {
-		return nil, fmt.Errorf("unable to retrieve the ordered pages from the assistant: %v", err)
+
+	if err := s.aiProxy.SendRequest(s.context, entities.AiCall{Folder: folder.path, Stage: entities.PageOrderingStage}, assistantPrompt, &orderedPages); err != nil {
+		return nil, fmt.Errorf("unable to retrieve the ordered pages from the assistant: %w", err)
+	}
+
+	return s.reconcilePages(folder, orderedPages, files, duplicates)
+}
+
+// reconcilePages checks the pages answered by the AI against the files of the folder, the copies left out of the
+// prompt being no pages. A folder whose answer had to be repaired is queued for review, and left aside when pages are
+// missing from the answer.
+func (s *ScannerService) reconcilePages(folder issueFolder, orderedPages []entities.MagazinePage, files []os.DirEntry, duplicates []string) ([]entities.MagazinePage, error) {
+
+	reconciliation := reconcilePages(orderedPages, files, duplicates)
+
+	for _, repair := range reconciliation.repairs {
+		s.auditService.Log(entities.Audit{Severity: entities.Warning, Timestamp: time.Now(), Text: fmt.Sprintf("Pages of folder '%s' repaired: %s", folder.name, repair)})
+	}
+
+	details := reconciliation.details()
+
+	if len(details) == 0 {
+		return reconciliation.pages, nil
+	}
+
+	reason := "the page list of the AI does not match the folder"
+
+	if len(reconciliation.missing) > 0 {
+		reason = fmt.Sprintf("the page list of the AI misses %d file(s) of the folder", len(reconciliation.missing))
+	}
+
+	s.auditService.Log(entities.Audit{Severity: entities.Warning, Timestamp: time.Now(), Text: fmt.Sprintf("The folder '%s' is queued for review: %s (%s)", folder.name, reason, strings.Join(details, "; "))})
+
+	s.reviewService.Add(review.ReviewItem{
+		Folder:     folder.path,
+		Stage:      entities.PageOrderingStage,
+		Reason:     reason,
+		Details:    details,
+		Candidates: reconciliation.pages,
+	})
+
+	//	The missing pages cannot be placed without a guess
+	if len(reconciliation.missing) > 0 {
+		return nil, errQueuedForReview
 	}
 
-	return orderedPages, nil
+	return reconciliation.pages, nil
 }
 
 func (s *ScannerService) Pages() <-chan entities.MagazinePages {
@@ -0,0 +1,69 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"organizer/internal/audit"
+)
+
+// newTestFolder writes the empty files of an issue folder, returning it along with its entries.
+func newTestFolder(t *testing.T, fileNames []string) (issueFolder, []os.DirEntry) {
+
+	folder := issueFolder{path: t.TempDir(), name: "Issue 12"}
+
+	for _, file := range fileNames {
+		if err := os.WriteFile(filepath.Join(folder.path, file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(folder.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return folder, entries
+}
+
+func newTestScanner(t *testing.T) *ScannerService {
+
+	//	The audit log is written to the current directory
+	t.Chdir(t.TempDir())
+
+	auditService, err := audit.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &ScannerService{localPageOrdering: true, orderingConfidence: 0.9, auditService: auditService}
+}
+
+func TestPagesLimitLeavesTheCopiesOut(t *testing.T) {
+
+	s := newTestScanner(t)
+
+	//	200 pages, 60 of them copied by the file manager
+	fileNames := pageFiles(200)
+
+	for _, file := range fileNames[:60] {
+		fileNames = append(fileNames, strings.TrimSuffix(file, ".jpg")+" (1).jpg")
+	}
+
+	folder, entries := newTestFolder(t, fileNames)
+
+	pages, err := s.getMagazinePages(folder, entries)
+
+	if err != nil || len(pages) != 200 {
+		t.Fatalf("expected the 200 pages to be ordered, got %d page(s), %v", len(pages), err)
+	}
+
+	folder, entries = newTestFolder(t, pageFiles(maxPages+1))
+
+	if _, err := s.getMagazinePages(folder, entries); err == nil || !strings.Contains(err.Error(), fmt.Sprintf("%d pages", maxPages+1)) {
+		t.Errorf("expected %d pages to be refused, got %v", maxPages+1, err)
+	}
+}